@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+
+	"github.com/fuale/eicg/internal/lexer"
+	"github.com/fuale/eicg/internal/parser"
+	"github.com/fuale/eicg/internal/printer"
+)
+
+// runFmt - is the `exig fmt [-w] <file>...` command, which prints sources in the canonical form.
+// With `-w` the sources are rewritten in place instead.
+func runFmt(args []string) int {
+	set := flag.NewFlagSet(os.Args[0]+" fmt", flag.ContinueOnError)
+	write := set.Bool("w", false, "write result to the source instead of stdout")
+	if err := set.Parse(args); err != nil {
+		return ExitUsage
+	}
+
+	if set.NArg() == 0 {
+		fmt.Printf("Usage: %s fmt [-w] <file>...\n", os.Args[0])
+		return ExitUsage
+	}
+
+	code := ExitOK
+	for _, source := range set.Args() {
+		if err := formatFile(source, *write); err != nil {
+			log.Printf("fail formatting %s: %s", source, err)
+
+			if code == ExitOK {
+				code = exitCode(err)
+			}
+		}
+	}
+
+	return code
+}
+
+// formatFile - formats the source, and prints it or writes it back.
+// Source is read whole, because it may be overwritten.
+func formatFile(source string, write bool) error {
+	src, err := os.ReadFile(source)
+	if err != nil {
+		return fmt.Errorf("fail obtaining resource: %w", err)
+	}
+
+	// Comments are a part of the source, so they must survive formatting
+	ast, tokens, err := parseWithComments(src)
+	if err != nil {
+		return parseFailure(err)
+	}
+
+	formatted := printer.New(ast).PrintEicg(tokens)
+
+	if err := checkFormatted(tokens, formatted); err != nil {
+		return err
+	}
+
+	if !write {
+		fmt.Print(formatted)
+		return nil
+	}
+
+	if err := os.WriteFile(source, []byte(formatted), 0644); err != nil {
+		return fmt.Errorf("%w: %w", errWrite, err)
+	}
+
+	return nil
+}
+
+// checkFormatted - ensures that the formatted source parses and keeps every comment
+// of the source in the same order. Source is lost once it is overwritten,
+// so the output is checked before that.
+func checkFormatted(tokens []lexer.Token, formatted string) error {
+	_, formattedTokens, err := parseWithComments([]byte(formatted))
+	if err != nil {
+		return fmt.Errorf("%w: formatted source doesn't parse: %w", errPrint, err)
+	}
+
+	if want, got := comments(tokens), comments(formattedTokens); !reflect.DeepEqual(want, got) {
+		return fmt.Errorf("%w: formatting loses comments, %d of %d are kept", errPrint, len(got), len(want))
+	}
+
+	return nil
+}
+
+// parseWithComments - parses the source, keeping comments, and returns its tokens too.
+func parseWithComments(src []byte) (parser.Statement, []lexer.Token, error) {
+	lex := lexer.New(bytes.NewReader(src))
+	lex.KeepComments = true
+
+	return parser.New(lex).ParseTokens()
+}
+
+// comments - returns texts of comments among the tokens.
+func comments(tokens []lexer.Token) []string {
+	texts := make([]string, 0)
+	for _, token := range tokens {
+		if token.Typ == lexer.TokenComment {
+			texts = append(texts, token.Value)
+		}
+	}
+
+	return texts
+}
@@ -1,60 +1,296 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/fuale/eicg/internal"
+	"github.com/fuale/eicg/internal/compiler"
 	"github.com/fuale/eicg/internal/lexer"
-	"github.com/fuale/eicg/internal/parser"
-	"github.com/fuale/eicg/internal/printer"
+)
+
+// Exit codes. Every failure class has its own code,
+// so scripts can tell a typo in the program from a missing file.
+// When several files fail, the code of the first failure is used.
+const (
+	ExitOK = 0
+
+	// ExitFailure - is any failure not covered by the codes below, e.g. the printer.
+	ExitFailure = 1
+
+	// ExitNotFound - source file or directory doesn't exist.
+	ExitNotFound = 2
+
+	// ExitLexError - source contains a malformed token, e.g. an unterminated string.
+	ExitLexError = 3
+
+	// ExitParseError - tokens don't form a valid program.
+	ExitParseError = 4
+
+	// ExitWriteError - output file can't be written.
+	ExitWriteError = 5
+
+	// ExitUsage - invalid flags or arguments. It is EINVAL.
+	ExitUsage = 22
+)
+
+// Pipeline stages, which are wrapped into errors of `compileFile` to pick the exit code.
+var (
+	errLex   = errors.New("fail lexing")
+	errParse = errors.New("fail parsing")
+	errPrint = compiler.ErrPrint
+	errWrite = errors.New("fail writing output")
 )
 
 func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run - is the whole program, but returns the exit code instead of exiting.
+func run(args []string) int {
 	setupLogger()
-	flags := setupFlags()
 
+	if len(args) > 0 && args[0] == "fmt" {
+		return runFmt(args[1:])
+	}
+
+	flags, err := setupFlags(args)
+	if err != nil {
+		return ExitUsage
+	}
+
+	if flags.Version {
+		fmt.Printf("exig %s\n", getVersion())
+		return ExitOK
+	}
+
+	opts := compiler.Options{Target: flags.Emit, Strict: flags.Strict, Debug: flags.Debug}
+	compile := func(source string) error {
+		return compileFile(source, opts)
+	}
+	if flags.Emit == "tokens" {
+		compile = emitTokens
+	}
+
+	// In watch mode errors are reported, but don't stop the process.
+	if flags.Watch {
+		if len(flags.Sources) != 1 {
+			fmt.Printf("Usage: %s -watch <file>\n", os.Args[0])
+			return ExitUsage
+		}
+
+		newWatcher(flags.Sources[0], compile).run(nil)
+		return ExitOK
+	}
+
+	// Patterns are expanded here, because not every shell does that, e.g. on Windows
+	sources, unmatched, err := expandGlobs(flags.Sources)
+	if err != nil {
+		log.Printf("fail expanding sources: %s", err)
+		return ExitUsage
+	}
+
+	code := ExitOK
+	for _, pattern := range unmatched {
+		log.Printf("pattern %s matches no files", pattern)
+		code = ExitNotFound
+	}
+
+	if flags.Recursive {
+		var err error
+		if sources, err = walkSources(sources); err != nil {
+			log.Printf("fail walking sources: %s", err)
+			return exitCode(err)
+		}
+	}
+
+	// Every file is compiled, even if some of them fail,
+	// so all errors are reported at once.
+	failed := 0
+	for _, source := range sources {
+		if err := compile(source); err != nil {
+			log.Printf("fail compiling %s: %s", source, err)
+			failed++
+
+			if code == ExitOK {
+				code = exitCode(err)
+			}
+		}
+	}
+
+	if len(sources) > 1 || flags.Recursive {
+		fmt.Printf("compiled %d of %d files\n", len(sources)-failed, len(sources))
+	}
+
+	return code
+}
+
+// exitCode - picks the exit code for an error of `compileFile`.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, errWrite):
+		// Checked before `fs.ErrNotExist`, which is also returned for a missing output directory
+		return ExitWriteError
+	case errors.Is(err, errLex):
+		return ExitLexError
+	case errors.Is(err, errParse):
+		return ExitParseError
+	case errors.Is(err, fs.ErrNotExist):
+		return ExitNotFound
+	default:
+		return ExitFailure
+	}
+}
+
+// parseFailure - wraps an error of parsing with `errLex`, when the source can't
+// even be split into tokens, or with `errParse` otherwise.
+func parseFailure(err error) error {
+	for _, lexErr := range []error{lexer.ErrUnterminatedString, lexer.ErrMalformedNumber, lexer.ErrInvalidChar, lexer.ErrUnexpectedRune} {
+		if errors.Is(err, lexErr) {
+			return fmt.Errorf("%w: %w", errLex, err)
+		}
+	}
+
+	return fmt.Errorf("%w: %w", errParse, err)
+}
+
+// extensions - are extensions of outputs of every `-emit` target, besides "tokens".
+var extensions = map[string]string{
+	"python":     ".py",
+	"javascript": ".js",
+	"typescript": ".ts",
+	"go":         ".go",
+	"scheme":     ".scm",
+	"bash":       ".sh",
+}
+
+// compileFile - compiles the source to `opts.Target` and writes it next to the source.
+func compileFile(source string, opts compiler.Options) error {
 	// Open file for reading, but not read entire file.
-	src, err := os.Open(flags.Source)
+	src, err := os.Open(source)
 	if err != nil {
-		log.Fatalf("fail obtaining resource: %s", err)
+		return fmt.Errorf("fail obtaining resource: %w", err)
 	}
 
 	// Don't forget to close the file.
 	defer src.Close()
 
-	// Main pipeline.
+	// Main pipeline: lexer splits the file into tokens, parser parses the tokens
+	// into AST as they come, and printer walks the AST and prints it in the target language.
+	// See `compiler.CompileWithOptions`.
+	out, err := compiler.CompileWithOptions(context.Background(), src, opts)
+	if errors.Is(err, compiler.ErrPrint) {
+		return err
+	} else if err != nil {
+		return parseFailure(err)
+	}
 
-	// 1. Lexer. Splits the file into tokens.
-	//    Here lexer is just created and performs no
-	//    tokenization, basically, it is in a `idle` state.
-	lex := lexer.New(src)
+	if err := writeOutput(out, source, extensions[opts.Target]); err != nil {
+		return fmt.Errorf("%w: %w", errWrite, err)
+	}
+
+	if opts.Debug {
+		internal.DebugBlock(fmt.Sprintf("compiled to %s", opts.Target), out)
+	}
+
+	return nil
+}
+
+// expandGlobs - replaces every pattern in `paths` with the files it matches.
+// Paths without pattern runes are kept as is, even if they don't exist,
+// so they fail later with a proper error. Patterns which match nothing are returned in `unmatched`.
+func expandGlobs(paths []string) (sources []string, unmatched []string, err error) {
+	sources = make([]string, 0, len(paths))
+	for _, path := range paths {
+		if !strings.ContainsAny(path, "*?[") {
+			sources = append(sources, path)
+			continue
+		}
+
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s", err, path)
+		}
+
+		if len(matches) == 0 {
+			unmatched = append(unmatched, path)
+		}
+
+		sources = append(sources, matches...)
+	}
+
+	return sources, unmatched, nil
+}
 
-	// 2. Parser. Parses the tokens into ASTs.
-	//    When Parser tries to analyze the next token, it will
-	//    use lexer to provide one - this way lexer and parser will work simultaneously.
-	ast := parser.New(lex).Parse()
+// walkSources - replaces every directory in `paths` with all `.ei` files inside it,
+// at any depth. Outputs are written next to sources, so the output tree mirrors the source tree.
+func walkSources(paths []string) ([]string, error) {
+	sources := make([]string, 0, len(paths))
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
 
-	// 3. Printer. Prints the AST at specific format.
-	//    Printing is done by simply walking the AST and converting
-	//    `parser.Expression` to string.
-	python := printer.New(ast).PrintPython()
+			// Files given explicitly are compiled whatever their extension is
+			if !d.IsDir() && (path == root || filepath.Ext(path) == ".ei") {
+				sources = append(sources, path)
+			}
 
-	// 4. Write output.
-	writeOutput(python, flags.Source, ".py")
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	internal.DebugBlock("compiled to python", python)
+	return sources, nil
+}
+
+// emitTokens - prints tokens of the source one per line as `TYPE<tab>"value"<tab>location`,
+// including comments, without parsing them. It is meant for debugging the lexer.
+func emitTokens(source string) error {
+	src, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("fail obtaining resource: %w", err)
+	}
+
+	defer src.Close()
+
+	// Lexer skips comments by default, but they are worth seeing here
+	lex := lexer.New(src)
+	lex.KeepComments = true
+
+	for result := range lex.Stream() {
+		if result.Error != nil {
+			return fmt.Errorf("%w: %w", errLex, result.Error)
+		}
+
+		fmt.Printf("%s\t%q\t%s\n", result.Token.Typ, result.Token.Value, result.Token.Location)
+	}
+
+	return nil
 }
 
 // Helper function to write output to file.
-func writeOutput(value, source, extension string) {
+// Extension of the source is replaced, or appended if there is none.
+func writeOutput(value, source, extension string) error {
 	for i := len(source) - 1; i >= 0 && !os.IsPathSeparator(source[i]); i-- {
 		if source[i] == '.' {
-			os.WriteFile(source[:i]+extension, []byte(value), 0644)
+			return os.WriteFile(source[:i]+extension, []byte(value), 0644)
 		}
 	}
+
+	return os.WriteFile(source+extension, []byte(value), 0644)
 }
 
 // Helper function to setup logger, which makes it logs the filename and location.
@@ -64,20 +300,54 @@ func setupLogger() {
 }
 
 type Flags struct {
-	Source string
+	Sources   []string
+	Debug     bool
+	Watch     bool
+	Recursive bool
+	Version   bool
+
+	// Strict - enables strict checks of every stage, see `compiler.Options.Strict`.
+	Strict bool
+
+	// Emit - is what to output: a program in one of `extensions`, or "tokens" for debugging.
+	Emit string
 }
 
+// emitValues - lists `extensions` for usage, in a stable order.
+const emitValues = "python, javascript, typescript, go, scheme, bash"
+
 // Helper function to get arguments and flags.
-func setupFlags() Flags {
-	flag.Parse()
-	source := flag.Arg(0)
+// Error is returned when arguments are invalid, usage is already printed then.
+func setupFlags(args []string) (Flags, error) {
+	set := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	debug := set.Bool("debug", false, "print tokens and AST while compiling")
+	watch := set.Bool("watch", false, "recompile whenever the source changes")
+	recursive := set.Bool("recursive", false, "compile every .ei file in the given directories")
+	version := set.Bool("version", false, "print version and exit")
+	strict := set.Bool("strict", false, "reject suspicious programs, like calls to unknown functions")
+	emit := set.String("emit", "python", "what to output: "+emitValues+" or tokens")
+	if err := set.Parse(args); err != nil {
+		return Flags{}, err
+	}
 
-	if source == "" {
-		fmt.Printf("Usage: %s <file>\n", os.Args[0])
-		os.Exit(22)
+	if _, ok := extensions[*emit]; !ok && *emit != "tokens" {
+		fmt.Printf("Unknown -emit value %q, must be %s or tokens\n", *emit, emitValues)
+		return Flags{}, fmt.Errorf("unknown emit %q", *emit)
 	}
+	sources := set.Args()
 
-	return Flags{
-		Source: source,
+	if len(sources) == 0 && !*version {
+		fmt.Printf("Usage: %s <file>...\n", os.Args[0])
+		return Flags{}, errors.New("no sources given")
 	}
+
+	return Flags{
+		Sources:   sources,
+		Debug:     *debug,
+		Watch:     *watch,
+		Recursive: *recursive,
+		Version:   *version,
+		Strict:    *strict,
+		Emit:      *emit,
+	}, nil
 }
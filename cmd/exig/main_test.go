@@ -0,0 +1,317 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runCLI - runs the program with the arguments and returns the exit code and everything it printed.
+func runCLI(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+
+	code := run(args)
+	w.Close()
+	os.Stdout = stdout
+
+	return code, <-out
+}
+
+// writeFiles - creates files in the directory, with parent directories.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// exists - tells whether the file exists.
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestMultipleFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.ei":   "Print[1]",
+		"bad.ei": "Print[\"unterminated]",
+		"b.ei":   "Print[2]",
+	})
+
+	code, out := runCLI(t, filepath.Join(dir, "a.ei"), filepath.Join(dir, "bad.ei"), filepath.Join(dir, "b.ei"))
+	if code != ExitLexError {
+		t.Errorf("got exit code %d, want %d", code, ExitLexError)
+	}
+
+	// Failure of one file doesn't stop the others
+	for _, name := range []string{"a.py", "b.py"} {
+		if !exists(filepath.Join(dir, name)) {
+			t.Errorf("%s is not written", name)
+		}
+	}
+	if exists(filepath.Join(dir, "bad.py")) {
+		t.Error("output of the failed file is written")
+	}
+
+	if !strings.Contains(out, "bad.ei") || !strings.Contains(out, "compiled 2 of 3 files") {
+		t.Errorf("no error or summary in the output:\n%s", out)
+	}
+}
+
+func TestRecursive(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.ei":           "Print[1]",
+		"lib/util.ei":       "Def[F, Args[x], x]",
+		"lib/deep/inner.ei": "Print[2]",
+		"lib/notes.txt":     "not a source",
+		"lib/bad.ei":        "Print[-1]",
+	})
+
+	code, out := runCLI(t, "-recursive", dir)
+	if code != ExitLexError {
+		t.Errorf("got exit code %d, want %d", code, ExitLexError)
+	}
+
+	// Outputs mirror the source tree
+	for _, name := range []string{"main.py", "lib/util.py", "lib/deep/inner.py"} {
+		if !exists(filepath.Join(dir, name)) {
+			t.Errorf("%s is not written", name)
+		}
+	}
+	if exists(filepath.Join(dir, "lib/notes.py")) {
+		t.Error("not a source is compiled")
+	}
+
+	if !strings.Contains(out, "bad.ei") || !strings.Contains(out, "compiled 3 of 4 files") {
+		t.Errorf("no error or summary in the output:\n%s", out)
+	}
+
+	if code, _ := runCLI(t, "-recursive", filepath.Join(dir, "missing")); code != ExitNotFound {
+		t.Errorf("missing directory: got exit code %d, want %d", code, ExitNotFound)
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"ok.ei":      "Print[1]",
+		"lex.ei":     "Print[\"unterminated]",
+		"parse.ei":   "Print[1,,2]",
+		"print.ei":   "Print[Eq[1]]",
+		"write.ei":   "Print[1]",
+		"write.py/x": "output path is a directory",
+	})
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"ok", []string{path("ok.ei")}, ExitOK},
+		{"no sources", []string{}, ExitUsage},
+		{"unknown flag", []string{"-unknown", path("ok.ei")}, ExitUsage},
+		{"unknown emit", []string{"-emit", "cobol", path("ok.ei")}, ExitUsage},
+		{"not found", []string{path("missing.ei")}, ExitNotFound},
+		{"lex error", []string{path("lex.ei")}, ExitLexError},
+		{"parse error", []string{path("parse.ei")}, ExitParseError},
+		{"print error", []string{path("print.ei")}, ExitFailure},
+		{"write error", []string{path("write.ei")}, ExitWriteError},
+		// The first failure picks the code
+		{"several failures", []string{path("ok.ei"), path("parse.ei"), path("lex.ei")}, ExitParseError},
+	}
+
+	for _, tt := range tests {
+		if code, out := runCLI(t, tt.args...); code != tt.want {
+			t.Errorf("%s: got exit code %d, want %d, output:\n%s", tt.name, code, tt.want, out)
+		}
+	}
+}
+
+func TestVersion(t *testing.T) {
+	code, out := runCLI(t, "-version")
+	if code != ExitOK || !strings.HasPrefix(out, "exig ") {
+		t.Errorf("got exit code %d, output %q", code, out)
+	}
+
+	// Version set at build time wins over build info
+	defer func(v string) { version = v }(version)
+	version = "v1.2.3"
+
+	if code, out := runCLI(t, "-version"); code != ExitOK || out != "exig v1.2.3\n" {
+		t.Errorf("got exit code %d, output %q", code, out)
+	}
+}
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.ei":     "Print[1]",
+		"b.ei":     "Print[2]",
+		"c.txt":    "not a source",
+		"sub/d.ei": "Print[3]",
+	})
+
+	code, out := runCLI(t, filepath.Join(dir, "*.ei"))
+	if code != ExitOK {
+		t.Errorf("got exit code %d, output:\n%s", code, out)
+	}
+	for name, want := range map[string]bool{"a.py": true, "b.py": true, "c.py": false, "sub/d.py": false} {
+		if exists(filepath.Join(dir, name)) != want {
+			t.Errorf("%s: written is %v, want %v", name, !want, want)
+		}
+	}
+
+	code, out = runCLI(t, filepath.Join(dir, "*.nothing"))
+	if code != ExitNotFound || !strings.Contains(out, "matches no files") {
+		t.Errorf("got exit code %d, output:\n%s", code, out)
+	}
+
+	if code, _ := runCLI(t, filepath.Join(dir, "[")); code != ExitUsage {
+		t.Errorf("malformed pattern: got exit code %d, want %d", code, ExitUsage)
+	}
+}
+
+func TestEmitTokens(t *testing.T) {
+	want, err := os.ReadFile(filepath.Join("testdata", "tokens.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, out := runCLI(t, "-emit", "tokens", filepath.Join("testdata", "tokens.ei"))
+	if code != ExitOK {
+		t.Fatalf("got exit code %d, output:\n%s", code, out)
+	}
+	if out != string(want) {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+
+	// Tokens are only printed, nothing is written
+	if exists(filepath.Join("testdata", "tokens.py")) {
+		t.Error("output is written")
+	}
+}
+
+func TestEmitTargets(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.ei": "Print[1]"})
+
+	for target, extension := range extensions {
+		if code, out := runCLI(t, "-emit", target, filepath.Join(dir, "a.ei")); code != ExitOK {
+			t.Errorf("%s: got exit code %d, output:\n%s", target, code, out)
+		}
+		if !exists(filepath.Join(dir, "a"+extension)) {
+			t.Errorf("%s: a%s is not written", target, extension)
+		}
+	}
+
+	// Bash can't print a list, which is a print error
+	writeFiles(t, dir, map[string]string{"list.ei": "Print[List[1]]"})
+	if code, out := runCLI(t, "-emit", "bash", filepath.Join(dir, "list.ei")); code != ExitFailure {
+		t.Errorf("got exit code %d, want %d, output:\n%s", code, ExitFailure, out)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"typo.ei": "Pirnt[1]", "glued.ei": "Print[1abc]"})
+
+	if code, out := runCLI(t, filepath.Join(dir, "typo.ei")); code != ExitOK {
+		t.Errorf("got exit code %d without -strict, output:\n%s", code, out)
+	}
+	if code, out := runCLI(t, "-strict", filepath.Join(dir, "typo.ei")); code != ExitFailure {
+		t.Errorf("got exit code %d, want %d, output:\n%s", code, ExitFailure, out)
+	}
+	if code, out := runCLI(t, "-strict", filepath.Join(dir, "glued.ei")); code != ExitLexError {
+		t.Errorf("got exit code %d, want %d, output:\n%s", code, ExitLexError, out)
+	}
+}
+
+func TestDebug(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.ei": "Print[1]"})
+
+	code, out := runCLI(t, "-debug", "-emit", "scheme", filepath.Join(dir, "a.ei"))
+	if code != ExitOK {
+		t.Fatalf("got exit code %d, output:\n%s", code, out)
+	}
+	for _, want := range []string{"TOKEN:", "AST", "compiled to scheme", "(print 1)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("no %q in the output:\n%s", want, out)
+		}
+	}
+
+	// Debug is per run, so the next run without the flag prints nothing
+	if code, out := runCLI(t, filepath.Join(dir, "a.ei")); code != ExitOK || out != "" {
+		t.Errorf("got exit code %d, output:\n%s", code, out)
+	}
+}
+
+func TestFmt(t *testing.T) {
+	src := "// header\nDef[Square, Args[x], // the argument\n  Mul[x, x]]\n\n\nPrint[Square[2] == 4]\n"
+	want := "// header\nDef[\n\tSquare,\n\tArgs[x],\n\t// the argument\n\tMul[x, x]\n]\n\nPrint[Square[2] == 4]\n"
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.ei")
+	writeFiles(t, dir, map[string]string{"main.ei": src})
+
+	if code, out := runCLI(t, "fmt", path); code != ExitOK || out != want {
+		t.Errorf("got exit code %d, output:\n%s\nwant:\n%s", code, out, want)
+	}
+
+	if code, _ := runCLI(t, "fmt", "-w", path); code != ExitOK {
+		t.Fatalf("got exit code %d", code)
+	}
+	if got, _ := os.ReadFile(path); string(got) != want {
+		t.Errorf("written:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestFmtLosingComments - output, which loses a comment, is refused.
+func TestFmtLosingComments(t *testing.T) {
+	_, tokens, err := parseWithComments([]byte("Print[1, // one\n2] // two\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkFormatted(tokens, "Print[\n\t1,\n\t// one\n\t2\n]\n// two\n"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	for _, formatted := range []string{
+		"Print[1, 2]\n// two\n",
+		"Print[\n\t1,\n\t// two\n\t2\n]\n// one\n",
+		"Print[1, // one\n",
+	} {
+		if err := checkFormatted(tokens, formatted); !errors.Is(err, errPrint) {
+			t.Errorf("%q: expected errPrint, got %v", formatted, err)
+		}
+	}
+}
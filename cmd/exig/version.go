@@ -0,0 +1,24 @@
+package main
+
+import "runtime/debug"
+
+// version - is set at build time with
+//
+//	go build -ldflags "-X main.version=v1.2.3" ./cmd/exig
+//
+// Otherwise the module version from build info is used,
+// which is known when installed with `go install ...@version`.
+var version = ""
+
+// getVersion - returns the version of the running build.
+func getVersion() string {
+	if version != "" {
+		return version
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+
+	return "(devel)"
+}
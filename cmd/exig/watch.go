@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"time"
+)
+
+// watcher - recompiles the source every time it changes.
+// It polls the file instead of subscribing to filesystem events,
+// which works everywhere and needs no dependencies.
+//
+// Editors often write a file several times on a single save,
+// so compilation starts only after the file was quiet for `debounce`.
+type watcher struct {
+	source string
+
+	// interval - is how often the file is checked.
+	interval time.Duration
+
+	// debounce - is how long the file must stay unchanged before compiling.
+	debounce time.Duration
+
+	// These are replaceable, so the watcher can run with a fake clock and filesystem.
+	stat    func(name string) (fs.FileInfo, error)
+	sleep   func(d time.Duration)
+	now     func() time.Time
+	compile func(source string) error
+}
+
+func newWatcher(source string, compile func(source string) error) *watcher {
+	return &watcher{
+		source:   source,
+		interval: 200 * time.Millisecond,
+		debounce: 100 * time.Millisecond,
+		stat:     os.Stat,
+		sleep:    time.Sleep,
+		now:      time.Now,
+		compile:  compile,
+	}
+}
+
+// run - watches until `stop` is closed. Nil `stop` means forever.
+// The source is compiled once at start, like it has just changed.
+func (w *watcher) run(stop <-chan struct{}) {
+	var lastModTime time.Time
+	var lastSize int64 = -1
+
+	pending := true
+	changedAt := w.now()
+
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		info, err := w.stat(w.source)
+		if err != nil {
+			// File may be temporary missing while editor replaces it
+			log.Printf("fail obtaining resource: %s", err)
+		} else if !info.ModTime().Equal(lastModTime) || info.Size() != lastSize {
+			lastModTime, lastSize = info.ModTime(), info.Size()
+			pending = true
+			changedAt = w.now()
+		}
+
+		if pending && w.now().Sub(changedAt) >= w.debounce {
+			pending = false
+
+			// Errors don't stop watching, the next save may fix them
+			if err := w.compile(w.source); err != nil {
+				log.Printf("fail compiling %s: %s", w.source, err)
+			} else {
+				log.Printf("compiled %s", w.source)
+			}
+		}
+
+		w.sleep(w.interval)
+	}
+}
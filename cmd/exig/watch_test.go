@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+)
+
+// fakeFile - is a file, which is saved at the given moments, for a fake `stat`.
+type fakeFile struct {
+	saves []time.Time
+	now   *time.Time
+}
+
+func (f fakeFile) modTime() time.Time {
+	var mod time.Time
+	for _, save := range f.saves {
+		if !save.After(*f.now) {
+			mod = save
+		}
+	}
+	return mod
+}
+
+func (f fakeFile) Name() string       { return "source.ei" }
+func (f fakeFile) Size() int64        { return 1 }
+func (f fakeFile) Mode() fs.FileMode  { return 0644 }
+func (f fakeFile) ModTime() time.Time { return f.modTime() }
+func (f fakeFile) IsDir() bool        { return false }
+func (f fakeFile) Sys() any           { return nil }
+
+func TestWatch(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	now := start
+	file := fakeFile{
+		// Saved before watching, then three times in a row by an editor
+		saves: []time.Time{at(-1000), at(1000), at(1050), at(1100)},
+		now:   &now,
+	}
+
+	stop := make(chan struct{})
+	compiled := make([]time.Time, 0)
+
+	w := newWatcher("source.ei", nil)
+	w.interval = 50 * time.Millisecond
+	w.debounce = 100 * time.Millisecond
+	w.stat = func(string) (fs.FileInfo, error) { return file, nil }
+	w.now = func() time.Time { return now }
+	w.sleep = func(d time.Duration) {
+		now = now.Add(d)
+		if !now.Before(at(2000)) {
+			close(stop)
+		}
+	}
+	w.compile = func(string) error {
+		compiled = append(compiled, now)
+		// The first compilation fails, which must not stop watching
+		if len(compiled) == 1 {
+			return errors.New("parse error")
+		}
+		return nil
+	}
+
+	w.run(stop)
+
+	// Once at start, then once for all three saves, after the last one is quiet for `debounce`
+	want := []time.Time{at(100), at(1200)}
+	if len(compiled) != len(want) {
+		t.Fatalf("compiled at %v, want %v", compiled, want)
+	}
+	for i := range want {
+		if !compiled[i].Equal(want[i]) {
+			t.Errorf("compilation %d at %s, want %s", i, compiled[i].Sub(start), want[i].Sub(start))
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package compiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/fuale/eicg/internal/lexer"
+	"github.com/fuale/eicg/internal/parser"
+)
+
+// ErrPrint - wraps errors of the printer, so they can be told from errors of lexing
+// and parsing, which mean the source itself is malformed.
+var ErrPrint = errors.New("fail printing")
+
+// Compile - runs the whole pipeline over the source and returns a python program.
+// It is the same pipeline as in `cmd/exig`, but errors are returned instead of exiting.
+func Compile(src io.Reader) (string, error) {
+	return CompileContext(context.Background(), src)
+}
+
+// CompileContext - is like `Compile`, but aborts with `ctx.Err()` when ctx is cancelled.
+// Both lexer and parser watch the context, so even a huge source stops quickly,
+// which is useful for servers compiling user-submitted programs.
+func CompileContext(ctx context.Context, src io.Reader) (string, error) {
+	return CompileWithOptions(ctx, src, Options{})
+}
+
+// CompileWithOptions - is like `CompileContext`, but every stage is configured by `opts`.
+func CompileWithOptions(ctx context.Context, src io.Reader, opts Options) (string, error) {
+	lex := lexer.NewContext(ctx, src)
+	lex.Strict = opts.strictLexer()
+	lex.Concurrent = opts.Concurrent
+	lex.Debug = opts.Debug
+	lex.KeepComments = opts.KeepComments && opts.python()
+	lex.TabWidth = opts.TabWidth
+	lex.MaxBytes = opts.MaxBytes
+
+	ps := parser.New(lex)
+	ps.Strict = opts.strictParser()
+	ps.MaxTokens = opts.MaxTokens
+	ps.Arena = opts.Arena
+	ps.Debug = opts.Debug
+
+	ast, err := ps.ParseContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := opts.print(ast)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrPrint, err)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,267 @@
+package compiler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fuale/eicg/internal/lexer"
+	"github.com/fuale/eicg/internal/parser"
+	"github.com/fuale/eicg/internal/printer/printers/python"
+)
+
+// cancelReader - cancels the context once the given number of bytes is read,
+// so the compilation is cancelled in the middle of the source.
+type cancelReader struct {
+	source io.Reader
+	after  int
+	cancel context.CancelFunc
+}
+
+func (r *cancelReader) Read(b []byte) (int, error) {
+	n, err := r.source.Read(b)
+	r.after -= n
+	if r.after <= 0 {
+		r.cancel()
+	}
+	return n, err
+}
+
+// program - generates a valid program of n top-level calls.
+func program(n int) string {
+	return strings.Repeat("Print[List[1, 2, 3], \"x\"]\n", n)
+}
+
+func TestCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	src := &cancelReader{source: strings.NewReader(program(100000)), after: 64 * 1024, cancel: cancel}
+	if _, err := CompileContext(ctx, src); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	// Nothing is compiled with an already cancelled context
+	if _, err := CompileContext(ctx, strings.NewReader(program(1))); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	if _, err := CompileContext(context.Background(), strings.NewReader(program(100))); err != nil {
+		t.Errorf("not cancelled compilation failed: %s", err)
+	}
+}
+
+func TestEmptySource(t *testing.T) {
+	for _, src := range []string{"", " \t\n\r\n", "// only a comment", "// one\n\n// two\n", "\uFEFF", ";;"} {
+		out, err := Compile(strings.NewReader(src))
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", src, err)
+		}
+		if out != "" {
+			t.Errorf("%q: expected empty output, got %q", src, out)
+		}
+	}
+}
+
+// TestBOM - a source with a byte order mark compiles to the same program as without it.
+func TestBOM(t *testing.T) {
+	src := "Def[F, Args[x], Add[x, 1]]\nPrint[F[1]]"
+	for _, target := range []string{"python", "javascript", "go", "scheme"} {
+		if got, want := compileWith(t, Options{Target: target}, "\uFEFF"+src), compileWith(t, Options{Target: target}, src); got != want {
+			t.Errorf("%s: got:\n%s\nwant:\n%s", target, got, want)
+		}
+	}
+}
+
+// compileWith - compiles the source with the options, failing the test on error.
+func compileWith(t *testing.T, opts Options, src string) string {
+	t.Helper()
+
+	out, err := CompileWithOptions(context.Background(), strings.NewReader(src), opts)
+	if err != nil {
+		t.Fatalf("%q: unexpected error: %s", src, err)
+	}
+
+	return out
+}
+
+// compileErr - compiles the source with the options and returns the error.
+func compileErr(opts Options, src string) error {
+	_, err := CompileWithOptions(context.Background(), strings.NewReader(src), opts)
+	return err
+}
+
+func TestTarget(t *testing.T) {
+	for target, want := range map[string]string{
+		"":           "builtin__print(1)",
+		"python":     "builtin__print(1)",
+		"javascript": "builtin__print(1);",
+		"typescript": "builtin__print(1);",
+		"go":         "fmt.Println(1)",
+		"scheme":     "(print 1)",
+		"bash":       "echo \"1\"",
+	} {
+		if got := compileWith(t, Options{Target: target}, "Print[1]"); !strings.Contains(got, want) {
+			t.Errorf("%q: expected %q in:\n%s", target, want, got)
+		}
+	}
+
+	if err := compileErr(Options{Target: "cobol"}, "Print[1]"); !errors.Is(err, ErrUnknownTarget) || !errors.Is(err, ErrPrint) {
+		t.Errorf("expected ErrUnknownTarget, got %v", err)
+	}
+}
+
+// TestStrict - every stage has its own strict flag, and `Strict` enables all of them.
+func TestStrict(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		opts Options
+		err  error
+	}{
+		{"Print[1abc]", Options{StrictLexer: true}, lexer.ErrMalformedNumber},
+		{"Print[1, 2", Options{StrictParser: true}, parser.ErrUnclosedBracket},
+		{"Pirnt[1]", Options{StrictPrinter: true}, python.ErrInvalidCall},
+	} {
+		// `1abc` is a number and a name by default, which is a parse error here
+		if err := compileErr(Options{}, tt.src); errors.Is(err, tt.err) {
+			t.Errorf("%q: not strict compilation failed with %s", tt.src, err)
+		}
+		if err := compileErr(tt.opts, tt.src); !errors.Is(err, tt.err) {
+			t.Errorf("%q: expected %v, got %v", tt.src, tt.err, err)
+		}
+		if err := compileErr(Options{Strict: true}, tt.src); !errors.Is(err, tt.err) {
+			t.Errorf("%q: expected %v with Strict, got %v", tt.src, tt.err, err)
+		}
+	}
+
+	// Strictness of one stage doesn't affect the others
+	if err := compileErr(Options{StrictLexer: true, StrictParser: true}, "Pirnt[1]"); err != nil {
+		t.Errorf("unknown function is rejected without StrictPrinter: %s", err)
+	}
+	if err := compileErr(Options{StrictPrinter: true}, "Print[1abc]"); err == nil || errors.Is(err, lexer.ErrMalformedNumber) {
+		t.Errorf("expected only the printer to fail, got %v", err)
+	}
+}
+
+func TestKeepComments(t *testing.T) {
+	src := "// greeting\nPrint[1]"
+	if got := compileWith(t, Options{KeepComments: true}, src); !strings.Contains(got, "# greeting") {
+		t.Errorf("comment is lost:\n%s", got)
+	}
+	if got := compileWith(t, Options{}, src); strings.Contains(got, "greeting") {
+		t.Errorf("comment is kept by default:\n%s", got)
+	}
+}
+
+func TestTabWidth(t *testing.T) {
+	for width, want := range map[int]string{0: ":1:2", 4: ":1:5", 8: ":1:9"} {
+		err := compileErr(Options{TabWidth: width}, "\t@")
+		if !errors.Is(err, lexer.ErrUnexpectedRune) || !strings.Contains(err.Error(), want) {
+			t.Errorf("tab width %d: expected error at %s, got %v", width, want, err)
+		}
+	}
+}
+
+func TestIndent(t *testing.T) {
+	src := "Def[F, Args[x], Do[Print[x], x]]"
+	if got := compileWith(t, Options{Indent: "\t", PlainPrint: true}, src); got != "def F(x):\n\tprint(x)\n\treturn x" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestHelperPrefix(t *testing.T) {
+	got := compileWith(t, Options{HelperPrefix: "eicg_"}, "Print[1]")
+	if !strings.Contains(got, "def eicg_print") || strings.Contains(got, "builtin__") {
+		t.Errorf("prefix is not applied:\n%s", got)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	if err := compileErr(Options{MaxBytes: 8}, program(1)); !errors.Is(err, lexer.ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+	if err := compileErr(Options{MaxTokens: 8}, program(1)); !errors.Is(err, parser.ErrTooManyTokens) {
+		t.Errorf("expected ErrTooManyTokens, got %v", err)
+	}
+	compileWith(t, Options{MaxBytes: 1024, MaxTokens: 1024}, program(1))
+}
+
+func TestPlainPrint(t *testing.T) {
+	if got := compileWith(t, Options{PlainPrint: true}, "Print[1]"); got != "print(1)" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestInlineAssoc(t *testing.T) {
+	if got := compileWith(t, Options{PlainPrint: true, InlineAssoc: true}, "Print[Assoc[\"k\", 1, A]]"); got != "print({**A, \"k\": 1})" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestMatchStatement(t *testing.T) {
+	src := "Def[F, Args[x], Do[Match[x, 1, \"one\", \"other\"]]]"
+	if got := compileWith(t, Options{MatchStatement: true}, src); !strings.Contains(got, "match x:") {
+		t.Errorf("no match statement:\n%s", got)
+	}
+	if got := compileWith(t, Options{}, src); strings.Contains(got, "match") {
+		t.Errorf("match statement by default:\n%s", got)
+	}
+}
+
+// TestArena - the arena may be reset and reused right after compilation.
+func TestArena(t *testing.T) {
+	arena := parser.NewArena()
+	want := compileWith(t, Options{}, program(10))
+
+	for i := 0; i < 3; i++ {
+		if got := compileWith(t, Options{Arena: arena}, program(10)); got != want {
+			t.Errorf("run %d: got:\n%s\nwant:\n%s", i, got, want)
+		}
+		arena.Reset()
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	if got, want := compileWith(t, Options{Concurrent: true}, program(10)), compileWith(t, Options{}, program(10)); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// captureStdout - returns what `f` prints to stdout.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	f()
+	w.Close()
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(out)
+}
+
+// TestDebug - debug output is printed only for the compilation which asked for it.
+func TestDebug(t *testing.T) {
+	out := captureStdout(t, func() { compileWith(t, Options{Debug: true}, "Print[1]") })
+	if !strings.Contains(out, "TOKEN:") || !strings.Contains(out, "AST") {
+		t.Errorf("no tokens or AST in debug output:\n%s", out)
+	}
+
+	if out := captureStdout(t, func() { compileWith(t, Options{}, "Print[1]") }); out != "" {
+		t.Errorf("debug output without Debug:\n%s", out)
+	}
+}
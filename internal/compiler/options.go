@@ -0,0 +1,115 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fuale/eicg/internal/parser"
+	"github.com/fuale/eicg/internal/printer/printers/bash"
+	"github.com/fuale/eicg/internal/printer/printers/golang"
+	"github.com/fuale/eicg/internal/printer/printers/javascript"
+	"github.com/fuale/eicg/internal/printer/printers/python"
+	"github.com/fuale/eicg/internal/printer/printers/scheme"
+	"github.com/fuale/eicg/internal/printer/printers/typescript"
+)
+
+var ErrUnknownTarget = errors.New("unknown target")
+
+// Options - configures every stage of the pipeline at once.
+// Zero value gives the default behavior, the same as `Compile`.
+type Options struct {
+	// Target - is the output language: "python", "javascript", "typescript",
+	// "go", "scheme" or "bash". Empty means "python".
+	Target string
+
+	// Strict - enables strict checks of every stage: names glued to numbers
+	// in lexer, reserved names and unclosed brackets in parser, and calls
+	// to unknown functions in the python printer.
+	Strict bool
+
+	// StrictLexer, StrictParser and StrictPrinter - enable strict checks of a single stage,
+	// see `Strict`. Checks are unrelated, so a program may e.g. have balanced brackets,
+	// but call functions the printer can't know about, like ones of a python module.
+	StrictLexer   bool
+	StrictParser  bool
+	StrictPrinter bool
+
+	// KeepComments - keeps top-level comments in the output.
+	// Only python target prints comments, for other targets it is ignored.
+	KeepComments bool
+
+	// TabWidth - is the distance between tab stops in the source, used for error locations.
+	TabWidth int
+
+	// Indent - is one level of indentation of the python output. Empty means two spaces.
+	Indent string
+
+	// HelperPrefix - is prepended to names of python helpers. Empty means `python.DefaultHelperPrefix`.
+	HelperPrefix string
+
+	// PlainPrint, InlineAssoc and MatchStatement - change how the python printer
+	// lowers builtins, see `python.Printer`. Other targets ignore them.
+	PlainPrint     bool
+	InlineAssoc    bool
+	MatchStatement bool
+
+	// Arena - allocates argument lists of the AST, see `parser.Parser.Arena`.
+	// The AST is printed before returning, so the arena may be reset right after the call.
+	Arena *parser.Arena
+
+	// Concurrent - enables locking in the lexer, see `lexer.Lexer.Concurrent`.
+	// The pipeline reads tokens from a single goroutine, so it only adds overhead.
+	Concurrent bool
+
+	// MaxBytes and MaxTokens - limit the size of the source, see `lexer.Lexer.MaxBytes`
+	// and `parser.Parser.MaxTokens`. Zero means no limit.
+	MaxBytes  int
+	MaxTokens int
+
+	// Debug - prints tokens and AST while compiling, see `lexer.Lexer.Debug`
+	// and `parser.Parser.Debug`. It affects only this compilation.
+	Debug bool
+}
+
+// strictLexer, strictParser and strictPrinter - tell whether strict checks of the stage are enabled.
+func (o Options) strictLexer() bool   { return o.Strict || o.StrictLexer }
+func (o Options) strictParser() bool  { return o.Strict || o.StrictParser }
+func (o Options) strictPrinter() bool { return o.Strict || o.StrictPrinter }
+
+// python - tells whether the target is python.
+func (o Options) python() bool {
+	return o.Target == "" || o.Target == "python"
+}
+
+// print - prints the AST with the printer of the target.
+func (o Options) print(ast parser.Statement) (string, error) {
+	switch o.Target {
+	case "", "python":
+		pp := python.Printer{
+			Strict:         o.strictPrinter(),
+			Indent:         o.Indent,
+			HelperPrefix:   o.HelperPrefix,
+			PlainPrint:     o.PlainPrint,
+			InlineAssoc:    o.InlineAssoc,
+			MatchStatement: o.MatchStatement,
+		}
+		return pp.String(ast)
+	case "javascript":
+		jp := javascript.Printer{}
+		return jp.String(ast)
+	case "typescript":
+		tp := typescript.Printer{}
+		return tp.String(ast)
+	case "go":
+		gp := golang.Printer{}
+		return gp.String(ast)
+	case "scheme":
+		sp := scheme.Printer{}
+		return sp.String(ast)
+	case "bash":
+		bp := bash.Printer{}
+		return bp.String(ast)
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUnknownTarget, o.Target)
+}
@@ -2,17 +2,71 @@ package lexer
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"strings"
+	"sync"
 	"unicode"
 )
 
+// MaxLookahead - is the deepest token `Peek` can look at. Grammar needs only two
+// tokens of lookahead, so deeper peeks are most likely a bug, which would
+// otherwise grow the token queue without a bound.
+const MaxLookahead = 8
+
+var ErrInvalidPeek = errors.New("invalid peek count")
+
+var ErrUnterminatedString = errors.New("unterminated string")
+
+var ErrMalformedNumber = errors.New("malformed number")
+
+var ErrInvalidChar = errors.New("invalid char")
+
+var ErrTooLarge = errors.New("source too large")
+
+var ErrUnexpectedRune = errors.New("unexpected rune")
+
+// Lexer - is not safe for concurrent use by default: every compilation
+// is expected to have its own Lexer, which is the case when each goroutine
+// calls `New`. If a single Lexer must be shared between goroutines,
+// set `Concurrent` before the first use, then every method that touches
+// the token queue is guarded by a mutex.
 type Lexer struct {
+	// Concurrent - enables locking, so the lexer can be shared between goroutines.
+	Concurrent bool
+
+	// KeepComments - makes line comments `TokenComment`s, instead of skipping them.
+	KeepComments bool
+
+	// TabWidth - is the distance between tab stops. Tab moves the column to the next stop,
+	// so columns match the caret in an editor with the same setting.
+	// Zero means 1, then a tab is a single column, like any other rune.
+	TabWidth int
+
+	// Strict - rejects a number immediately followed by a name, like `1abc`, with `ErrMalformedNumber`.
+	// By default it is a number and a name, which silently splits what was probably meant as one name.
+	Strict bool
+
+	// MaxBytes - limits the size of the source, lexing fails with `ErrTooLarge` after that many bytes.
+	// It protects a hosted compiler from huge inputs. Zero means no limit.
+	MaxBytes int
+
+	// Debug - prints every lexed token. It is off by default,
+	// because formatting every token takes more time than lexing it.
+	Debug bool
+
+	// mu - guards `tokenQueue` and reading from `source` when `Concurrent` is set.
+	mu sync.Mutex
+
 	// Row - is the current row in which the cursor is located.
+	// Rows and columns start from 1, like in editors.
 	row int
 
 	// Column - is, respectively, column of the current row.
+	// Column is counted in runes, except tabs, see `TabWidth`.
 	col int
 
 	// Source - is the source file reader.
@@ -22,53 +76,159 @@ type Lexer struct {
 
 	// TokenQueue - is the queue of tokens that have been read from the source but not yet parsed.
 	// It is used to keep tokens, that we peeked, but not yet consumed.
-	tokenQueue []TokenResult
+	// Queue never holds more than `MaxLookahead` tokens, so it is a fixed ring buffer:
+	// consuming just moves `queueHead`, without reslicing or allocating.
+	tokenQueue [MaxLookahead]TokenResult
+
+	// queueHead - is the index of the first queued token in `tokenQueue`.
+	queueHead int
+
+	// queueLen - is the number of queued tokens.
+	queueLen int
+
+	// nameBuffer and numberBuffer - are reused between tokens by `next`,
+	// so collecting runes of a name or number doesn't allocate every time.
+	// Token values are still independent, because `string(runes)` copies.
+	nameBuffer   []rune
+	numberBuffer []rune
+
+	// started - is set after the first rune is read, so only a leading byte order mark is skipped.
+	started bool
+
+	// ctx - is checked before lexing every token, so lexing a huge
+	// source can be aborted. Cancelled lexer returns `ctx.Err()`.
+	ctx context.Context
 }
 
 // Constructs a new Lexer from io.Reader
 func New(source io.Reader) *Lexer {
-	return &Lexer{
-		source: bufio.NewReader(source),
+	return NewContext(context.Background(), source)
+}
+
+// Constructs a new Lexer, which stops lexing when ctx is cancelled
+func NewContext(ctx context.Context, source io.Reader) *Lexer {
+	l := &Lexer{
+		ctx: ctx,
+		row: 1,
+		col: 1,
+	}
+
+	// Limit is checked while reading, because it may be set after the lexer is created
+	l.source = bufio.NewReader(&limitReader{lexer: l, source: source})
+	return l
+}
+
+// limitReader - reads the source up to `Lexer.MaxBytes`, and fails with `ErrTooLarge` after that.
+type limitReader struct {
+	lexer  *Lexer
+	source io.Reader
+	read   int
+}
+
+func (r *limitReader) Read(b []byte) (int, error) {
+	limit := r.lexer.MaxBytes
+	if limit <= 0 {
+		return r.source.Read(b)
+	}
+
+	if r.read > limit {
+		return 0, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, limit)
+	}
+
+	// One byte over the limit is read to tell a source of exactly `MaxBytes` from a larger one
+	if rest := limit + 1 - r.read; len(b) > rest {
+		b = b[:rest]
+	}
+
+	n, err := r.source.Read(b)
+	r.read += n
+	if r.read > limit {
+		// The extra byte is dropped, so it is never lexed
+		return n - 1, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, limit)
 	}
+
+	return n, err
 }
 
 // Consume - consumes token from `tokenQueue`
 // and not trigger lexer to lex new token. Used for peeking.
 func (l *Lexer) Consume() {
-	if len(l.tokenQueue) < 1 {
+	l.lock()
+	defer l.unlock()
+
+	if l.queueLen < 1 {
 		log.Fatal("consume called with empty queue")
 	}
 
-	l.tokenQueue = l.tokenQueue[1:]
+	l.dequeue()
 }
 
-// Peek - peek the next token at specified position in tokenQueue
+// Peek - peek the next token at specified position in tokenQueue.
+// Position starts at 1 and can't be greater than `MaxLookahead`.
 func (l *Lexer) Peek(count int) (Token, error) {
+	l.lock()
+	defer l.unlock()
+
+	if err := checkPeek(count); err != nil {
+		return UnknownToken, err
+	}
+
 	// Make sure we have enough tokens in tokenQueue
-	for i := len(l.tokenQueue); i < count; i += 1 {
+	for l.queueLen < count {
 		token, err := l.lognext()
 
 		// Simply append to queue without checking for error
-		l.tokenQueue = append(l.tokenQueue, TokenResult{Token: token, Error: err})
+		l.enqueue(TokenResult{Token: token, Error: err})
 	}
 
 	// If we have enough tokens in tokenQueue,
 	// return the token at count-1, which is token index
-	token := l.tokenQueue[count-1]
+	token := l.queued(count - 1)
 
 	return token.Token, token.Error
 }
 
+// Peek2 - returns the next two tokens at once, without consuming them.
+// Error is the error of the first token. When the second one can't be lexed,
+// e.g. at the end of file, it is `UnknownToken`, and its error is returned by
+// a later call, once it is the first token.
+func (l *Lexer) Peek2() (Token, Token, error) {
+	l.lock()
+	defer l.unlock()
+
+	for l.queueLen < 2 {
+		token, err := l.lognext()
+		l.enqueue(TokenResult{Token: token, Error: err})
+	}
+
+	first, second := l.queued(0), l.queued(1)
+	if first.Error != nil {
+		return UnknownToken, UnknownToken, first.Error
+	}
+	if second.Error != nil {
+		return first.Token, UnknownToken, nil
+	}
+
+	return first.Token, second.Token, nil
+}
+
 // MustPeek - peek the next token at specified position in tokenQueue
 // but throws fatal error if there is no token at specified position.
 // Used in alghorithms, where must be at least `count` tokens.
 func (l *Lexer) MustPeek(count int) Token {
-	for i := len(l.tokenQueue); i < count; i += 1 {
+	l.lock()
+	defer l.unlock()
+
+	if err := checkPeek(count); err != nil {
+		log.Fatal(err)
+	}
+
+	for l.queueLen < count {
 		token, err := l.lognext()
-		l.tokenQueue = append(l.tokenQueue, TokenResult{Token: token, Error: err})
+		l.enqueue(TokenResult{Token: token, Error: err})
 	}
 
-	token := l.tokenQueue[count-1]
+	token := l.queued(count - 1)
 
 	if token.Error != nil {
 		log.Fatal(token.Error)
@@ -78,15 +238,25 @@ func (l *Lexer) MustPeek(count int) Token {
 	return token.Token
 }
 
+// checkPeek - validates position of a peeked token.
+func checkPeek(count int) error {
+	if count < 1 || count > MaxLookahead {
+		return fmt.Errorf("%w: %d, must be between 1 and %d", ErrInvalidPeek, count, MaxLookahead)
+	}
+
+	return nil
+}
+
 // Next - is like `next`, but returns token from queue
 // if there is any and then removes it from queue.
 func (l *Lexer) Next() (Token, error) {
+	l.lock()
+	defer l.unlock()
+
 	// Check `tokenQueue` is not empty
-	if len(l.tokenQueue) > 0 {
-		// pick it up...
-		t := l.tokenQueue[0]
-		// ...and remove
-		l.tokenQueue = l.tokenQueue[1:]
+	if l.queueLen > 0 {
+		// pick it up and remove
+		t := l.dequeue()
 		return t.Token, t.Error
 	}
 
@@ -95,28 +265,113 @@ func (l *Lexer) Next() (Token, error) {
 
 // MustNext - is like `Next`, but throws fatal error if there is no token.
 func (l *Lexer) MustNext() Token {
-	if len(l.tokenQueue) > 0 {
-		t := l.tokenQueue[0]
+	l.lock()
+	defer l.unlock()
+
+	if l.queueLen > 0 {
+		t := l.dequeue()
 		if t.Error != nil {
 			log.Fatal(t.Error)
 		}
 
-		l.tokenQueue = l.tokenQueue[1:]
-
 		return t.Token
 	}
 
-	t, err := l.Next()
+	// Queue is empty, so lex the next token directly.
+	// Calling `Next` here would try to lock again.
+	t, err := l.lognext()
 	if err != nil {
 		log.Fatal(err)
 	}
 	return t
 }
 
-// lognext - it is a `next` decorator that logs the next token.
+// enqueue - appends token to the end of `tokenQueue`. Queue can't overflow,
+// because it is filled only up to the peeked position, which is checked by `checkPeek`.
+func (l *Lexer) enqueue(t TokenResult) {
+	l.tokenQueue[(l.queueHead+l.queueLen)%MaxLookahead] = t
+	l.queueLen += 1
+}
+
+// dequeue - removes and returns the first token of `tokenQueue`.
+func (l *Lexer) dequeue() TokenResult {
+	t := l.tokenQueue[l.queueHead]
+	l.queueHead = (l.queueHead + 1) % MaxLookahead
+	l.queueLen -= 1
+	return t
+}
+
+// queued - returns the queued token at index, starting from 0.
+func (l *Lexer) queued(index int) TokenResult {
+	return l.tokenQueue[(l.queueHead+index)%MaxLookahead]
+}
+
+// lock - locks the lexer, but only when it is shared between goroutines.
+func (l *Lexer) lock() {
+	if l.Concurrent {
+		l.mu.Lock()
+	}
+}
+
+// unlock - is the counterpart of `lock`.
+func (l *Lexer) unlock() {
+	if l.Concurrent {
+		l.mu.Unlock()
+	}
+}
+
+// Row - returns the row of the cursor, that is where the next token will be lexed from.
+// Cursor is right after the last lexed token, which may be only peeked, not consumed yet.
+// Rows and columns are counted the same way as in token `Location`s.
+func (l *Lexer) Row() int {
+	l.lock()
+	defer l.unlock()
+
+	return l.row
+}
+
+// Col - returns the column of the cursor in the current row, see `Row`.
+func (l *Lexer) Col() int {
+	l.lock()
+	defer l.unlock()
+
+	return l.col
+}
+
+// Stream - is an alternative to `Peek`/`Next` for pipeline-style consumers.
+// Tokens are lexed lazily, one by one as the consumer receives them,
+// so nothing is buffered. The channel is closed on EOF, any other error
+// is sent as the final TokenResult before closing.
+//
+// Consumer must drain the channel, or cancel the context given to
+// `NewContext`, otherwise the producing goroutine is leaked.
+func (l *Lexer) Stream() <-chan TokenResult {
+	stream := make(chan TokenResult)
+
+	go func() {
+		defer close(stream)
+
+		for {
+			token, err := l.Next()
+			if err == io.EOF {
+				return
+			}
+
+			stream <- TokenResult{Token: token, Error: err}
+
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return stream
+}
+
+// lognext - it is a `next` decorator that logs the next token, when `Debug` is set.
 func (l *Lexer) lognext() (Token, error) {
 	t, e := l.next()
-	if e == nil {
+	if l.Debug && e == nil {
 		fmt.Printf("TOKEN: [%+v, %+v]\n", t, e)
 	}
 	return t, e
@@ -124,12 +379,18 @@ func (l *Lexer) lognext() (Token, error) {
 
 // `next` - is the primary lexer function that does all the work.
 func (l *Lexer) next() (Token, error) {
+	// Stop early, if nobody waits for tokens anymore
+	if err := l.ctx.Err(); err != nil {
+		return UnknownToken, err
+	}
+
 	// name - array, which we use to collect runes,
-	//        which can possibly be a variable name, that means multiple runes
-	name := make([]rune, 0)
+	//        which can possibly be a variable name, that means multiple runes.
+	//        Array of the previous token is reused, so we only reset its length.
+	name := l.nameBuffer[:0]
 
 	// number - is the same as `name`, but for numbers.
-	number := make([]rune, 0)
+	number := l.numberBuffer[:0]
 
 	// searchName - boolean flag, which indicates that we currently lexing `name`
 	searchName := false
@@ -137,6 +398,9 @@ func (l *Lexer) next() (Token, error) {
 	// searchNumber - same for numbers
 	searchNumber := false
 
+	// fraction and exponent - are parts of the number already seen, each may appear only once
+	fraction, exponent := false, false
+
 	// flag - which needed for check double slashes for comments
 	maybeComment := false
 
@@ -144,17 +408,66 @@ func (l *Lexer) next() (Token, error) {
 	//            because tokens is not a recursive structure -
 	//            tokens, basically, is just array
 	for {
+		// Fraction needs a digit after the dot, otherwise it is a dot token,
+		// like in `1.method[]`. That's why we look ahead instead of reading:
+		// the dot can't be put back after peeking.
+		if searchNumber && !fraction && !exponent {
+			if ahead, err := l.source.Peek(2); err == nil && ahead[0] == '.' && ahead[1] >= '0' && ahead[1] <= '9' {
+				l.source.Discard(1)
+				number = append(number, '.')
+				l.col += 1
+				fraction = true
+				continue
+			}
+		}
+
 		// start lexing by reading one rune
 		r, _, err := l.source.ReadRune()
 		if err != nil {
 			// check for io.EOF.
 			// Need to explicitly handle `io.EOF` for properly handle end of file
 			if err == io.EOF {
+				// Name or number may end right at the end of file,
+				// then it is the last token and the next call gives EOF
+				if searchName {
+					l.nameBuffer = name
+					return Token{
+						Typ:      TokenName,
+						Value:    string(name),
+						Location: Location{Row: l.row, Col: l.col - len(name), File: ""},
+					}, nil
+				}
+				if searchNumber {
+					l.numberBuffer = number
+					return Token{
+						Typ:      TokenNumber,
+						Value:    string(number),
+						Location: Location{Row: l.row, Col: l.col - len(number), File: ""},
+					}, nil
+				}
+
+				if maybeComment {
+					return UnknownToken, l.unexpected('/')
+				}
+
 				return UnknownToken, err
 			}
 
-			// Otherwise throw fatal
-			log.Fatal(err)
+			// Otherwise it is an error of the source, e.g. `ErrTooLarge`
+			return UnknownToken, err
+		}
+
+		// Byte order mark, which some editors put at the start of UTF-8 files, is not a part of the source.
+		// Nothing is read before it, so the cursor is still at the very beginning.
+		leading := !l.started
+		l.started = true
+		if r == '\uFEFF' && leading {
+			continue
+		}
+
+		// Single slash is not a token, only a half of the comment start
+		if maybeComment && r != '/' {
+			return UnknownToken, l.unexpected('/')
 		}
 
 		// `switch true` - it's a trick to replace `if {} else if {}` over
@@ -168,9 +481,11 @@ func (l *Lexer) next() (Token, error) {
 		// and if so, we append the next rune to the array
 		// and continue until, finally, we encounter a non-letter rune.
 		case searchName:
-			// When we looking for a name, the first character should be a letter,
-			// while the second and the rest may be also a numbers.
-			if unicode.IsDigit(r) || unicode.IsLetter(r) {
+			// When we looking for a name, the first character should be a letter or underscore,
+			// while the second and the rest may be also a numbers, underscores,
+			// and combining marks, which some scripts need to spell a letter.
+			// Letters and digits of any script are allowed: `имя2` is a name.
+			if isNamePart(r) {
 				name = append(name, r)
 				// When appending a rune, don't forget to increase `col`
 				l.col += 1
@@ -180,6 +495,8 @@ func (l *Lexer) next() (Token, error) {
 				// it back in `source` buffer, because the last readed
 				// rune does not belongs to `name`
 				l.source.UnreadRune()
+				// Keep the array, it could have grown
+				l.nameBuffer = name
 				return Token{
 					Typ:      TokenName,
 					Value:    string(name),
@@ -193,8 +510,36 @@ func (l *Lexer) next() (Token, error) {
 				number = append(number, r)
 				l.col += 1
 				continue
+			} else if r == '_' && unicode.IsDigit(number[len(number)-1]) && l.followedByDigit(0) {
+				// Separator between digits: `1_000`. Backends keep or drop it with `NormalizeNumber`.
+				number = append(number, r)
+				l.col += 1
+				continue
+			} else if (r == 'e' || r == 'E') && !exponent {
+				// Exponent: `1e10`, `2.5e-3`. Sign is optional, but digits are not.
+				number = append(number, r)
+				l.col += 1
+				exponent = true
+
+				if sign, err := l.source.Peek(1); err == nil && (sign[0] == '+' || sign[0] == '-') && l.followedByDigit(1) {
+					l.source.ReadByte()
+					number = append(number, rune(sign[0]))
+					l.col += 1
+				} else if !l.followedByDigit(0) {
+					return UnknownToken, fmt.Errorf(
+						"%w: %s, exponent has no digits at %s",
+						ErrMalformedNumber, string(number), Location{Row: l.row, Col: l.col - len(number)}.String(),
+					)
+				}
+				continue
+			} else if l.Strict && (unicode.IsLetter(r) || r == '_') {
+				return UnknownToken, fmt.Errorf(
+					"%w: %s%c, name can't start with a digit at %s",
+					ErrMalformedNumber, string(number), r, Location{Row: l.row, Col: l.col - len(number)}.String(),
+				)
 			} else {
 				l.source.UnreadRune()
+				l.numberBuffer = number
 				return Token{
 					Typ:      TokenNumber,
 					Value:    string(number),
@@ -203,8 +548,9 @@ func (l *Lexer) next() (Token, error) {
 			}
 		}
 
-		// Here we start scanning for name
-		if unicode.IsLetter(r) {
+		// Here we start scanning for name.
+		// Underscore may start a name too, like python's `_private`, or be a name on its own.
+		if unicode.IsLetter(r) || r == '_' {
 			name = append(name, r)
 			l.col += 1
 			searchName = true
@@ -225,73 +571,219 @@ func (l *Lexer) next() (Token, error) {
 		// For example: if we encounter a equal sign,
 		// we immediatly return it as a token
 		case '=':
-			return Token{
-				Typ:      TokenEquals,
-				Value:    "=",
-				Location: Location{},
-			}, nil
+			// Equal sign may be the first half of `==`, so we need
+			// to look at the next rune before deciding what we've got.
+			next, _, err := l.source.ReadRune()
+			if err == nil && next == '=' {
+				return l.symbol(TokenEqualEqual, "=="), nil
+			}
+
+			// Not a comparison - put the rune back, it belongs to the next token.
+			if err == nil {
+				l.source.UnreadRune()
+			}
+
+			return l.symbol(TokenEquals, "="), nil
 		// When encounter a space, we need to strip it,
 		// advance position and continue as usual
 		case '\n', '\v', '\f', '\r':
+			// Windows line ending `\r\n` is a single newline
+			if next, err := l.source.Peek(1); r == '\r' && err == nil && next[0] == '\n' {
+				l.source.Discard(1)
+			}
 			l.row += 1
-			l.col = 0
+			l.col = 1
 			continue
-		case ' ', '\t', 0x85, 0xA0: // Some of weird runes a stolen from go's `unicode.IsSpace` builtin function
+		case '\t':
+			l.tab()
+			continue
+		case ' ', 0x85, 0xA0: // Some of weird runes a stolen from go's `unicode.IsSpace` builtin function
 			l.col += 1
 			continue
 		case '/':
 			// Same mechanic as with names
 			if maybeComment {
-				// Here we strip all runes to the end of the line
-				_, err = l.source.ReadBytes('\n')
-				if err != nil {
+				// Here we strip all runes to the end of the line,
+				// comment starts at the first slash
+				location := Location{Row: l.row, Col: l.col - 1, File: ""}
+				text, err := l.source.ReadString('\n')
+				if err != nil && (err != io.EOF || !l.KeepComments) {
 					return UnknownToken, err
 				}
 
+				// Newline is consumed together with the comment
+				if err == nil {
+					l.row += 1
+					l.col = 1
+				}
+
+				if l.KeepComments {
+					return Token{
+						Typ:      TokenComment,
+						Value:    strings.TrimRight(text, "\r\n"),
+						Location: location,
+					}, nil
+				}
+
 				maybeComment = false
 				continue
 			}
 
 			maybeComment = true
+			l.col += 1
 			continue
 		case '[':
-			return Token{
-				Typ:   TokenSquareBracketOpen,
-				Value: "[",
-				Location: Location{
-					Row:  l.row,
-					Col:  l.col,
-					File: "",
-				},
-			}, nil
+			return l.symbol(TokenSquareBracketOpen, "["), nil
 		case ']':
-			return Token{
-				Typ:   TokenSquareBracketClose,
-				Value: "]",
-				Location: Location{
-					Row:  l.row,
-					Col:  l.col,
-					File: "",
-				},
-			}, nil
+			return l.symbol(TokenSquareBracketClose, "]"), nil
 		case ',':
-			return Token{
-				Typ:   TokenComma,
-				Value: ",",
-				Location: Location{
-					Row:  l.row,
-					Col:  l.col,
-					File: "",
-				},
-			}, nil
+			return l.symbol(TokenComma, ","), nil
+		case ';':
+			return l.symbol(TokenSemicolon, ";"), nil
+		case '.':
+			return l.symbol(TokenDot, "."), nil
+		case '"':
+			return l.nextString()
+		case '\'':
+			return l.nextChar()
+		}
+
+		// Any other rune can't start a token. Treating it as the end of file
+		// would silently drop the rest of the source.
+		l.col += 1
+		return UnknownToken, l.unexpected(r)
+	}
+}
+
+// unexpected - returns `ErrUnexpectedRune` for the rune right before the cursor.
+func (l *Lexer) unexpected(r rune) error {
+	return fmt.Errorf("%w: %q at %s", ErrUnexpectedRune, r, Location{Row: l.row, Col: l.col - 1}.String())
+}
+
+// nextChar - scans a char literal, when opening quote is already read.
+// Literal must contain exactly one rune, or an escape sequence,
+// which is kept as is, like in strings.
+func (l *Lexer) nextChar() (Token, error) {
+	location := Location{Row: l.row, Col: l.col, File: ""}
+
+	// opening quote
+	l.col += 1
+
+	r, _, err := l.source.ReadRune()
+	if err != nil || r == '\n' {
+		return UnknownToken, fmt.Errorf("%w: unterminated at %s", ErrInvalidChar, location.String())
+	}
+	if r == '\'' {
+		return UnknownToken, fmt.Errorf("%w: empty at %s", ErrInvalidChar, location.String())
+	}
+	l.col += 1
+
+	value := []rune{r}
+	if r == '\\' {
+		escaped, _, err := l.source.ReadRune()
+		if err != nil {
+			return UnknownToken, fmt.Errorf("%w: unterminated at %s", ErrInvalidChar, location.String())
 		}
+		l.col += 1
+		value = append(value, escaped)
+	}
+
+	closing, _, err := l.source.ReadRune()
+	if err != nil || closing == '\n' {
+		return UnknownToken, fmt.Errorf("%w: unterminated at %s", ErrInvalidChar, location.String())
+	}
+	if closing != '\'' {
+		return UnknownToken, fmt.Errorf("%w: more than one rune at %s", ErrInvalidChar, location.String())
+	}
+	l.col += 1
+
+	return Token{
+		Typ:      TokenChar,
+		Value:    string(value),
+		Location: location,
+	}, nil
+}
+
+// isNamePart - tells whether the rune may continue a name, e.g. `snake_case`.
+func isNamePart(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '_'
+}
+
+// tab - moves the cursor to the next tab stop.
+func (l *Lexer) tab() {
+	width := l.TabWidth
+	if width < 1 {
+		width = 1
+	}
 
-		// I think, here we should throw an error,
-		// because we don't know what kind of rune it is.
-		break
+	l.col = ((l.col-1)/width+1)*width + 1
+}
+
+// symbol - makes a token of an operator or punctuation at the cursor, and moves the cursor past it.
+func (l *Lexer) symbol(typ TokenType, value string) Token {
+	location := Location{Row: l.row, Col: l.col, File: ""}
+	l.col += len(value)
+
+	return Token{
+		Typ:      typ,
+		Value:    value,
+		Location: location,
 	}
+}
+
+// followedByDigit - tells whether the rune `skip` bytes after the cursor is a digit, without reading it.
+func (l *Lexer) followedByDigit(skip int) bool {
+	next, err := l.source.Peek(skip + 1)
+	return err == nil && next[skip] >= '0' && next[skip] <= '9'
+}
+
+// nextString - scans a string literal, when opening quote is already read.
+// Token value is the raw contents between quotes, escape sequences
+// are kept as is, so backends can pass them through.
+func (l *Lexer) nextString() (Token, error) {
+	location := Location{Row: l.row, Col: l.col, File: ""}
+	value := make([]rune, 0)
+
+	// opening quote
+	l.col += 1
+
+	for {
+		r, _, err := l.source.ReadRune()
+		if err == io.EOF {
+			return UnknownToken, fmt.Errorf("%w at %s", ErrUnterminatedString, location.String())
+		}
+		if err != nil {
+			return UnknownToken, err
+		}
 
-	// If we reach here, we have reached the end of the file,
-	// or it is probably a bug
-	return UnknownToken, io.EOF
+		l.col += 1
+
+		switch r {
+		case '"':
+			return Token{
+				Typ:      TokenString,
+				Value:    string(value),
+				Location: location,
+			}, nil
+		case '\\':
+			// Escaped rune can't terminate the string, so take it as is.
+			escaped, _, err := l.source.ReadRune()
+			if err != nil {
+				return UnknownToken, fmt.Errorf("%w at %s", ErrUnterminatedString, location.String())
+			}
+			l.col += 1
+			value = append(value, r, escaped)
+		case '\n', '\r':
+			// Backends copy the value into their own string literals, which can't span lines,
+			// so a line break must be escaped, like `\n`. Otherwise it is most likely a missing quote.
+			return UnknownToken, fmt.Errorf("%w: line break at %s", ErrUnterminatedString, location.String())
+		case '\t':
+			// Rune is already counted as a single column above
+			l.col -= 1
+			l.tab()
+			value = append(value, r)
+		default:
+			value = append(value, r)
+		}
+	}
 }
@@ -0,0 +1,767 @@
+package lexer
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// lexAll - lexes the whole source, failing the test on any error besides EOF.
+func lexAll(t *testing.T, src string) []Token {
+	t.Helper()
+
+	l := New(strings.NewReader(src))
+	tokens := make([]Token, 0)
+	for {
+		token, err := l.Next()
+		if err == io.EOF {
+			return tokens
+		}
+		if err != nil {
+			t.Fatalf("lexing %q: %s", src, err)
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+// types - returns types of the tokens, which is what most tests compare.
+func types(tokens []Token) []TokenType {
+	typs := make([]TokenType, 0, len(tokens))
+	for _, token := range tokens {
+		typs = append(typs, token.Typ)
+	}
+	return typs
+}
+
+func equalTypes(a, b []TokenType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestEqualsAndEqualEqual(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []TokenType
+	}{
+		{"x = 1", []TokenType{TokenName, TokenEquals, TokenNumber}},
+		{"x == 1", []TokenType{TokenName, TokenEqualEqual, TokenNumber}},
+		{"x==1", []TokenType{TokenName, TokenEqualEqual, TokenNumber}},
+		{"x = = 1", []TokenType{TokenName, TokenEquals, TokenEquals, TokenNumber}},
+		{"x ===1", []TokenType{TokenName, TokenEqualEqual, TokenEquals, TokenNumber}},
+		{"x =", []TokenType{TokenName, TokenEquals}},
+	}
+
+	for _, tt := range tests {
+		if got := types(lexAll(t, tt.src)); !equalTypes(got, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+// TestIsolation - lexers of different goroutines share nothing,
+// so they need no locking. Run with `-race` to check it.
+func TestIsolation(t *testing.T) {
+	src := strings.Repeat("Def[F, Args[x], Print[x, \"s\", 'c', 1.5]]\n", 100)
+	want := types(lexAll(t, src))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			l := New(strings.NewReader(src))
+			got := make([]TokenType, 0, len(want))
+			for {
+				token, err := l.Next()
+				if err != nil {
+					break
+				}
+				got = append(got, token.Typ)
+			}
+
+			if !equalTypes(got, want) {
+				t.Errorf("goroutine got %d tokens, want %d", len(got), len(want))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// TestConcurrent - a `Concurrent` lexer may be shared, and every token is taken exactly once.
+func TestConcurrent(t *testing.T) {
+	src := strings.Repeat("Print[x, 1]\n", 1000)
+	want := len(lexAll(t, src))
+
+	l := New(strings.NewReader(src))
+	l.Concurrent = true
+
+	var wg sync.WaitGroup
+	var total int64
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if _, err := l.Peek(2); err != nil && err != io.EOF {
+					t.Error(err)
+					return
+				}
+				if _, err := l.Next(); err != nil {
+					return
+				}
+				atomic.AddInt64(&total, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if total != int64(want) {
+		t.Errorf("got %d tokens, want %d", total, want)
+	}
+}
+
+func TestStream(t *testing.T) {
+	src := "Print[x, \"s\"]"
+	want := lexAll(t, src)
+
+	got := make([]Token, 0)
+	for result := range New(strings.NewReader(src)).Stream() {
+		if result.Error != nil {
+			t.Fatalf("unexpected error: %s", result.Error)
+		}
+		got = append(got, result.Token)
+	}
+
+	if !equalTypes(types(got), types(want)) {
+		t.Errorf("got %v, want %v", types(got), types(want))
+	}
+
+	// Error is the last result, then the channel is closed
+	results := make([]TokenResult, 0)
+	for result := range New(strings.NewReader("Print[\"unterminated")).Stream() {
+		results = append(results, result)
+	}
+	if last := results[len(results)-1]; !errors.Is(last.Error, ErrUnterminatedString) {
+		t.Errorf("expected ErrUnterminatedString as the last result, got %v", last.Error)
+	}
+}
+
+func TestPeek(t *testing.T) {
+	l := New(strings.NewReader("a b c d e f g h i j"))
+
+	for _, count := range []int{0, -1, MaxLookahead + 1} {
+		if _, err := l.Peek(count); !errors.Is(err, ErrInvalidPeek) {
+			t.Errorf("Peek(%d): expected ErrInvalidPeek, got %v", count, err)
+		}
+	}
+
+	// The deepest peek doesn't consume anything
+	deep, err := l.Peek(MaxLookahead)
+	if err != nil || deep.Value != "h" {
+		t.Fatalf("Peek(%d): got %v, %v", MaxLookahead, deep, err)
+	}
+	for _, want := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"} {
+		if token, err := l.Next(); err != nil || token.Value != want {
+			t.Fatalf("Next: got %v, %v, want %q", token, err, want)
+		}
+	}
+
+	// Peeking past the end returns EOF
+	if _, err := l.Peek(MaxLookahead); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestPeek2(t *testing.T) {
+	l := New(strings.NewReader("x = F[1]"))
+
+	// Repeated peeks give the same tokens and consume nothing
+	for i := 0; i < 3; i++ {
+		first, second, err := l.Peek2()
+		if err != nil || first.Value != "x" || second.Typ != TokenEquals {
+			t.Fatalf("Peek2: got %v, %v, %v", first, second, err)
+		}
+	}
+
+	for _, want := range []string{"x", "=", "F", "[", "1", "]"} {
+		if token, err := l.Next(); err != nil || token.Value != want {
+			t.Fatalf("Next: got %v, %v, want %q", token, err, want)
+		}
+	}
+
+	// The second token may be missing at the end of file, the first one is still given
+	l = New(strings.NewReader("x"))
+	if first, second, err := l.Peek2(); err != nil || first.Value != "x" || second != UnknownToken {
+		t.Errorf("got %v, %v, %v", first, second, err)
+	}
+	if token, err := l.Next(); err != nil || token.Value != "x" {
+		t.Errorf("Next: got %v, %v", token, err)
+	}
+}
+
+func TestDot(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []TokenType
+	}{
+		{"obj.name", []TokenType{TokenName, TokenDot, TokenName}},
+		{"a.b.c", []TokenType{TokenName, TokenDot, TokenName, TokenDot, TokenName}},
+		{"1.5", []TokenType{TokenNumber}},
+		{"xs.append[1]", []TokenType{TokenName, TokenDot, TokenName, TokenSquareBracketOpen, TokenNumber, TokenSquareBracketClose}},
+	}
+
+	for _, tt := range tests {
+		if got := types(lexAll(t, tt.src)); !equalTypes(got, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+// TestUnexpectedRune - runes, which can't start a token, are errors rather than the end of file.
+func TestUnexpectedRune(t *testing.T) {
+	tests := []struct {
+		src      string
+		location string
+	}{
+		{"Print[-1]", ":1:7"},
+		{"Print[1]\nPrint[2 + 3]", ":2:9"},
+		{"Print[1] / 2", ":1:10"},
+		{"Print[1] /", ":1:10"},
+		{"x @", ":1:3"},
+	}
+
+	for _, tt := range tests {
+		l := New(strings.NewReader(tt.src))
+		var err error
+		for err == nil {
+			_, err = l.Next()
+		}
+
+		if !errors.Is(err, ErrUnexpectedRune) || !strings.HasSuffix(err.Error(), tt.location) {
+			t.Errorf("%q: expected ErrUnexpectedRune at %s, got %v", tt.src, tt.location, err)
+		}
+	}
+}
+
+// source - generates a program of about the given size in bytes.
+func source(size int) string {
+	line := "Def[F, Args[x, y], Print[Map[G, List[1, 2.5, \"str\", 'c', x_1]], y]]\n"
+	return strings.Repeat(line, size/len(line)+1)
+}
+
+// BenchmarkPeek - peeks two tokens before consuming every one, like the parser does.
+func BenchmarkPeek(b *testing.B) {
+	src := source(1 << 20)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		l := New(strings.NewReader(src))
+		for {
+			if _, _, err := l.Peek2(); err != nil {
+				break
+			}
+			if _, err := l.Peek(1); err != nil {
+				break
+			}
+			l.Consume()
+		}
+	}
+}
+
+// BenchmarkNext - lexes a megabyte of source with debug logging off.
+func BenchmarkNext(b *testing.B) {
+	src := source(1 << 20)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		l := New(strings.NewReader(src))
+		for {
+			if _, err := l.Next(); err != nil {
+				break
+			}
+		}
+	}
+}
+
+// BenchmarkAllocsPerToken - reports allocations per token. Rune buffers are reused,
+// so only token values allocate, and names or numbers don't cost more than symbols.
+func BenchmarkAllocsPerToken(b *testing.B) {
+	src := source(1 << 16)
+	tokens := 0
+	allocs := testing.AllocsPerRun(1, func() {
+		tokens = 0
+		l := New(strings.NewReader(src))
+		for {
+			if _, err := l.Next(); err != nil {
+				break
+			}
+			tokens++
+		}
+	})
+
+	for i := 0; i < b.N; i++ {
+		l := New(strings.NewReader(src))
+		for {
+			if _, err := l.Next(); err != nil {
+				break
+			}
+		}
+	}
+	b.ReportMetric(allocs/float64(tokens), "allocs/token")
+}
+
+// TestAdjacentTokens - values of tokens lexed with the same buffer are independent.
+func TestAdjacentTokens(t *testing.T) {
+	tokens := lexAll(t, "longname x 12345 6 abc")
+	want := []string{"longname", "x", "12345", "6", "abc"}
+	for i, token := range tokens {
+		if token.Value != want[i] {
+			t.Errorf("token %d: got %q, want %q", i, token.Value, want[i])
+		}
+	}
+}
+
+func TestKeepComments(t *testing.T) {
+	src := "// first\nPrint[1] // second\n//"
+
+	if got := types(lexAll(t, src)); !equalTypes(got, []TokenType{TokenName, TokenSquareBracketOpen, TokenNumber, TokenSquareBracketClose}) {
+		t.Errorf("comments are not skipped: %v", got)
+	}
+
+	l := New(strings.NewReader(src))
+	l.KeepComments = true
+	comments := make([]Token, 0)
+	for {
+		token, err := l.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.Typ == TokenComment {
+			comments = append(comments, token)
+		}
+	}
+
+	want := []struct {
+		text string
+		row  int
+	}{{" first", 1}, {" second", 2}, {"", 3}}
+	if len(comments) != len(want) {
+		t.Fatalf("got %v", comments)
+	}
+	for i, w := range want {
+		if comments[i].Value != w.text || comments[i].Location.Row != w.row {
+			t.Errorf("comment %d: got %q at %s, want %q at row %d", i, comments[i].Value, comments[i].Location, w.text, w.row)
+		}
+	}
+}
+
+// TestCommentTokens - comments end at a line break, and `//` inside a string is not a comment.
+func TestCommentTokens(t *testing.T) {
+	if got := TokenComment.String(); got != "comment" {
+		t.Errorf("got %q", got)
+	}
+
+	tests := []struct {
+		src  string
+		want []Token
+	}{
+		{"a// b", []Token{
+			{Typ: TokenName, Value: "a", Location: Location{Row: 1, Col: 1}},
+			{Typ: TokenComment, Value: " b", Location: Location{Row: 1, Col: 2}},
+		}},
+		{"// a\r\nb", []Token{
+			{Typ: TokenComment, Value: " a", Location: Location{Row: 1, Col: 1}},
+			{Typ: TokenName, Value: "b", Location: Location{Row: 2, Col: 1}},
+		}},
+		{"//a\n//b", []Token{
+			{Typ: TokenComment, Value: "a", Location: Location{Row: 1, Col: 1}},
+			{Typ: TokenComment, Value: "b", Location: Location{Row: 2, Col: 1}},
+		}},
+		{"\"//a\"", []Token{
+			{Typ: TokenString, Value: "//a", Location: Location{Row: 1, Col: 1}},
+		}},
+	}
+
+	for _, tt := range tests {
+		l := New(strings.NewReader(tt.src))
+		l.KeepComments = true
+
+		got := make([]Token, 0)
+		for {
+			token, err := l.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%q: %s", tt.src, err)
+			}
+			got = append(got, token)
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestScientificNumbers(t *testing.T) {
+	for _, src := range []string{"1e10", "2.5e-3", "1E+5", "0e0"} {
+		tokens := lexAll(t, src)
+		if len(tokens) != 1 || tokens[0].Typ != TokenNumber || tokens[0].Value != src {
+			t.Errorf("%q: got %v", src, tokens)
+		}
+	}
+
+	for _, src := range []string{"1e", "1e+", "2.5e-x", "1e]"} {
+		l := New(strings.NewReader(src))
+		if _, err := l.Next(); !errors.Is(err, ErrMalformedNumber) {
+			t.Errorf("%q: expected ErrMalformedNumber, got %v", src, err)
+		}
+	}
+}
+
+func TestDigitSeparators(t *testing.T) {
+	for _, src := range []string{"1_000", "1_000_000", "1_0.2_5", "1e1_0"} {
+		tokens := lexAll(t, src)
+		if len(tokens) != 1 || tokens[0].Typ != TokenNumber || tokens[0].Value != src {
+			t.Errorf("%q: got %v", src, tokens)
+		}
+	}
+
+	// Separator must be between digits, otherwise it starts a name
+	for _, src := range []string{"1_", "1__0", "1_e5"} {
+		l := New(strings.NewReader(src))
+		l.Strict = true
+		if _, err := l.Next(); !errors.Is(err, ErrMalformedNumber) {
+			t.Errorf("%q: expected ErrMalformedNumber, got %v", src, err)
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{`"hello"`, "hello"},
+		{`""`, ""},
+		{`"a\"b"`, `a\"b`},
+		{`"line\nbreak"`, `line\nbreak`},
+		{"\"tab\there\"", "tab\there"},
+	}
+
+	for _, tt := range tests {
+		tokens := lexAll(t, tt.src)
+		if len(tokens) != 1 || tokens[0].Typ != TokenString || tokens[0].Value != tt.want {
+			t.Errorf("%q: got %v, want %q", tt.src, tokens, tt.want)
+		}
+	}
+
+	// Line break must be escaped, so a missing quote is reported on its line
+	for _, src := range []string{"Print[\"a\nb\"]", "Print[\"a\r\nb\"]", "Print[\"unterminated]\nPrint[\"x\"]"} {
+		l := New(strings.NewReader(src))
+		var err error
+		for err == nil {
+			_, err = l.Next()
+		}
+		if !errors.Is(err, ErrUnterminatedString) || !strings.HasSuffix(err.Error(), "at :1:7") {
+			t.Errorf("%q: expected ErrUnterminatedString at :1:7, got %v", src, err)
+		}
+	}
+}
+
+func TestChars(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"'a'", "a"},
+		{"'ж'", "ж"},
+		{"' '", " "},
+		{`'\n'`, `\n`},
+		{`'\''`, `\'`},
+		{`'"'`, `"`},
+	}
+	for _, tt := range tests {
+		tokens := lexAll(t, tt.src)
+		if len(tokens) != 1 || tokens[0].Typ != TokenChar || tokens[0].Value != tt.want {
+			t.Errorf("%s: got %v, want char %q", tt.src, tokens, tt.want)
+		}
+	}
+
+	for _, src := range []string{"''", "'ab'", "'a", "'", "'\n'"} {
+		if _, err := New(strings.NewReader(src)).Next(); !errors.Is(err, ErrInvalidChar) {
+			t.Errorf("%q: expected ErrInvalidChar, got %v", src, err)
+		}
+	}
+}
+
+func TestUnicodeNames(t *testing.T) {
+	for _, src := range []string{"snake_case", "имя", "имя_2", "नमस्ते", "x٣", "café", "Ünïcödé"} {
+		tokens := lexAll(t, src)
+		if len(tokens) != 1 || tokens[0].Typ != TokenName || tokens[0].Value != src {
+			t.Errorf("%q: got %v", src, tokens)
+		}
+	}
+
+	// Digit of any script can't start a name
+	if got := types(lexAll(t, "٣x")); !equalTypes(got, []TokenType{TokenNumber, TokenName}) {
+		t.Errorf("got %v", got)
+	}
+
+	// Columns are counted in runes, not bytes
+	tokens := lexAll(t, "имя x")
+	if tokens[1].Location.Col != 5 {
+		t.Errorf("got column %d, want 5", tokens[1].Location.Col)
+	}
+}
+
+func TestUnderscoreNames(t *testing.T) {
+	for _, src := range []string{"_x", "__init__", "_", "_1", "x_"} {
+		tokens := lexAll(t, src)
+		if len(tokens) != 1 || tokens[0].Typ != TokenName || tokens[0].Value != src {
+			t.Errorf("%q: got %v", src, tokens)
+		}
+	}
+
+	if got := types(lexAll(t, "F[_, _x]")); !equalTypes(got, []TokenType{TokenName, TokenSquareBracketOpen, TokenName, TokenComma, TokenName, TokenSquareBracketClose}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestRowCol(t *testing.T) {
+	l := New(strings.NewReader("Print[x,\n  yy]"))
+	if l.Row() != 1 || l.Col() != 1 {
+		t.Fatalf("start: got %d:%d", l.Row(), l.Col())
+	}
+
+	// Cursor is right after the last lexed token
+	want := []struct{ row, col int }{{1, 6}, {1, 7}, {1, 8}, {1, 9}, {2, 5}, {2, 6}}
+	for i, w := range want {
+		token, err := l.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.Row() != w.row || l.Col() != w.col {
+			t.Errorf("after token %d %q: got %d:%d, want %d:%d", i, token.Value, l.Row(), l.Col(), w.row, w.col)
+		}
+		if token.Location.Row > l.Row() || (token.Location.Row == l.Row() && token.Location.Col >= l.Col()) {
+			t.Errorf("token %q at %s is not before the cursor", token.Value, token.Location)
+		}
+	}
+
+	// Peeked token is already lexed, so the cursor moves too
+	l = New(strings.NewReader("a bb"))
+	l.Peek(2)
+	if l.Col() != 5 {
+		t.Errorf("after peek: got column %d, want 5", l.Col())
+	}
+}
+
+func TestLocationsStartAtOne(t *testing.T) {
+	tokens := lexAll(t, "Print[1]\nPrint[2]")
+	if l := tokens[0].Location; l.Row != 1 || l.Col != 1 {
+		t.Errorf("first token at %d:%d, want 1:1", l.Row, l.Col)
+	}
+	if l := tokens[4].Location; l.Row != 2 || l.Col != 1 {
+		t.Errorf("first token of the second line at %d:%d, want 2:1", l.Row, l.Col)
+	}
+	if l := tokens[2].Location; l.Row != 1 || l.Col != 7 {
+		t.Errorf("number at %d:%d, want 1:7", l.Row, l.Col)
+	}
+
+	// Windows line endings are a single newline
+	if l := lexAll(t, "a\r\nb")[1].Location; l.Row != 2 || l.Col != 1 {
+		t.Errorf("after CRLF at %d:%d, want 2:1", l.Row, l.Col)
+	}
+}
+
+// TestCRLF - Windows line endings are counted once, so locations match the LF version of the source.
+func TestCRLF(t *testing.T) {
+	lf := "// header\nPrint[1]\n\n  Print[x, // note\n    2]\n"
+	crlf := strings.ReplaceAll(lf, "\n", "\r\n")
+
+	l := New(strings.NewReader(crlf))
+	l.KeepComments = true
+	got := make([]Token, 0)
+	for {
+		token, err := l.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, token)
+	}
+
+	want := []Location{
+		{Row: 1, Col: 1},
+		{Row: 2, Col: 1}, {Row: 2, Col: 6}, {Row: 2, Col: 7}, {Row: 2, Col: 8},
+		{Row: 4, Col: 3}, {Row: 4, Col: 8}, {Row: 4, Col: 9}, {Row: 4, Col: 10}, {Row: 4, Col: 12},
+		{Row: 5, Col: 5}, {Row: 5, Col: 6},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v", got)
+	}
+	for i := range want {
+		if got[i].Location != want[i] {
+			t.Errorf("token %d %q: at %s, want %s", i, got[i].Value, got[i].Location, want[i])
+		}
+	}
+
+	// Comments don't include the carriage return
+	if got[0].Value != " header" || got[9].Value != " note" {
+		t.Errorf("got comments %q and %q", got[0].Value, got[9].Value)
+	}
+
+	// A lone `\r` is still a newline, so `\r\r\n` is two of them
+	if l := lexAll(t, "a\rb\r\r\nc"); l[1].Location.Row != 2 || l[2].Location.Row != 4 {
+		t.Errorf("got rows %d and %d, want 2 and 4", l[1].Location.Row, l[2].Location.Row)
+	}
+}
+
+// TestBOM - a leading byte order mark is skipped, without shifting locations.
+func TestBOM(t *testing.T) {
+	src := "Print[\"a\"]\nPrint[1]"
+	if got, want := lexAll(t, "\uFEFF"+src), lexAll(t, src); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Only the leading one is a byte order mark, elsewhere it is an unexpected rune
+	for _, src := range []string{"Print[1]\uFEFF", " \uFEFFPrint[1]", "\uFEFF\uFEFFPrint[1]"} {
+		l := New(strings.NewReader(src))
+		var err error
+		for err == nil {
+			_, err = l.Next()
+		}
+		if !errors.Is(err, ErrUnexpectedRune) {
+			t.Errorf("%q: expected ErrUnexpectedRune, got %v", src, err)
+		}
+	}
+}
+
+func TestTabWidth(t *testing.T) {
+	tests := []struct {
+		width int
+		src   string
+		col   int
+	}{
+		{0, "\t\tx", 3},
+		{1, "\t\tx", 3},
+		{4, "\t\tx", 9},
+		{8, "\tx", 9},
+		// Tab moves to the next stop, not by a fixed distance
+		{4, "ab\tx", 5},
+		{4, "abcd\tx", 9},
+	}
+
+	for _, tt := range tests {
+		l := New(strings.NewReader(tt.src))
+		l.TabWidth = tt.width
+
+		var token Token
+		for token.Typ != TokenName || token.Value != "x" {
+			var err error
+			if token, err = l.Next(); err != nil {
+				t.Fatalf("%q: %s", tt.src, err)
+			}
+		}
+
+		if token.Location.Col != tt.col {
+			t.Errorf("%q with tab width %d: got column %d, want %d", tt.src, tt.width, token.Location.Col, tt.col)
+		}
+	}
+}
+
+func TestNameGluedToNumber(t *testing.T) {
+	// Not strict lexer splits it, as it always did
+	tokens := lexAll(t, "1abc")
+	if len(tokens) != 2 || tokens[0].Typ != TokenNumber || tokens[0].Value != "1" || tokens[1].Typ != TokenName || tokens[1].Value != "abc" {
+		t.Errorf("got %v", tokens)
+	}
+
+	for _, src := range []string{"1abc", "2.5x", "1e5f", "Print[1abc]", "42_"} {
+		l := New(strings.NewReader(src))
+		l.Strict = true
+		var err error
+		for err == nil {
+			_, err = l.Next()
+		}
+		if !errors.Is(err, ErrMalformedNumber) {
+			t.Errorf("%q: expected ErrMalformedNumber, got %v", src, err)
+		}
+	}
+
+	// Separators are fine in strict mode too
+	for _, src := range []string{"1 abc", "1,abc", "Print[1]", "1_000"} {
+		l := New(strings.NewReader(src))
+		l.Strict = true
+		for {
+			_, err := l.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("%q: unexpected error: %s", src, err)
+				break
+			}
+		}
+	}
+}
+
+// endless - is an infinite source, which repeats the program and counts the bytes read from it.
+type endless struct {
+	program string
+	read    int
+}
+
+func (e *endless) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = e.program[(e.read+i)%len(e.program)]
+	}
+	e.read += len(b)
+	return len(b), nil
+}
+
+func TestMaxBytes(t *testing.T) {
+	lex := func(src io.Reader, limit int) error {
+		l := New(src)
+		l.MaxBytes = limit
+		for {
+			if _, err := l.Next(); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+	}
+
+	// The limit is inclusive
+	if err := lex(strings.NewReader("Print[1]"), 8); err != nil {
+		t.Errorf("source of exactly MaxBytes: %s", err)
+	}
+	if err := lex(strings.NewReader("Print[1]"), 7); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+
+	// An endless source stops right after the limit, instead of being read forever
+	src := &endless{program: "Print[1]\n"}
+	if err := lex(src, 1<<16); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+	if src.read > 1<<16+1 {
+		t.Errorf("read %d bytes over the limit", src.read-1<<16)
+	}
+}
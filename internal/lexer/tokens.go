@@ -37,6 +37,18 @@ func (t TokenType) String() string {
 		return "slash"
 	case TokenEquals:
 		return "equals sign"
+	case TokenEqualEqual:
+		return "double equals sign"
+	case TokenString:
+		return "string"
+	case TokenDot:
+		return "dot"
+	case TokenComment:
+		return "comment"
+	case TokenChar:
+		return "char"
+	case TokenSemicolon:
+		return "semicolon"
 	default:
 		// When we encounter nil-token or unknown token we need to inform ourselves
 		log.Fatal("unreachable: trying to print null-token")
@@ -56,12 +68,19 @@ const (
 	TokenNumber
 	TokenSlash
 	TokenEquals
+	TokenEqualEqual
+	TokenString
+	TokenDot
+	TokenComment
+	TokenChar
+	TokenSemicolon
 )
 
 // Dummy token needed for passing it as non-pointer
 var UnknownToken = Token{Typ: TokenUnknown, Value: "<unknown>", Location: Location{}}
 
-// Location - is simple location of a token
+// Location - is simple location of a token.
+// Row and Col start from 1, zero Location means the location is unknown.
 type Location struct {
 	Col  int
 	Row  int
@@ -0,0 +1,265 @@
+package linter
+
+import (
+	"fmt"
+
+	"github.com/fuale/eicg/internal/parser"
+	"github.com/fuale/eicg/internal/printer/printers/python"
+)
+
+// Severity - is how likely the diagnostic points to a real bug.
+type Severity int
+
+const (
+	// SeverityHint - is something worth a look, but often intended.
+	SeverityHint Severity = iota
+
+	// SeverityWarning - is most likely a bug.
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityHint:
+		return "hint"
+	case SeverityWarning:
+		return "warning"
+	}
+
+	return "<unknown>"
+}
+
+// Diagnostic - is a single issue found by `Lint`.
+type Diagnostic struct {
+	Location parser.Location
+	Severity Severity
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s", d.Location, d.Severity, d.Message)
+}
+
+// Lint - reports common mistakes, which parse fine, but are likely bugs:
+// `Cond` with the wrong number of arguments, `Def` without a body,
+// unused `Let` bindings, duplicate keys of `HashMap` and calls to functions defined nowhere.
+// Python is the main target, so its builtins, like `len`, are not unknown functions.
+//
+// Unlike parse errors, diagnostics are advisory, printing doesn't depend on them.
+// Diagnostics are in source order.
+func Lint(s parser.Statement) []Diagnostic {
+	block, ok := s.(parser.BlockStatement)
+	if !ok {
+		return nil
+	}
+
+	l := linter{defined: make(map[string]struct{}), diagnostics: make([]Diagnostic, 0)}
+	for _, e := range block.Expressions {
+		l.define(e)
+	}
+
+	for _, e := range block.Expressions {
+		l.check(e)
+	}
+
+	return l.diagnostics
+}
+
+type linter struct {
+	// defined - are names defined anywhere in the program.
+	// Scopes are not tracked, so a name defined in one function
+	// is known in all of them. That's fine for advisory checks.
+	defined map[string]struct{}
+
+	diagnostics []Diagnostic
+}
+
+func (l *linter) report(e parser.Expression, severity Severity, format string, args ...any) {
+	l.diagnostics = append(l.diagnostics, Diagnostic{
+		Location: parser.LocationOf(e),
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// define - collects names bound by the expression and everything nested in it.
+func (l *linter) define(e parser.Expression) {
+	switch e := e.(type) {
+	case parser.VariableReferenceExpression:
+		// Names are reached only as definitions, see below
+		l.defined[e.Value] = struct{}{}
+	case parser.AssignmentExpression:
+		l.define(e.Lhs)
+		l.walk(e.Rhs)
+	case parser.TupleExpression:
+		for _, element := range e.Elements {
+			l.define(element)
+		}
+	case parser.CallExpression:
+		l.walk(e)
+	}
+}
+
+// walk - looks for definitions in arguments of the call.
+func (l *linter) walk(e parser.Expression) {
+	switch e := e.(type) {
+	case parser.AssignmentExpression:
+		// Assignments in `Do` bind names, keyword arguments bind nothing, but it doesn't hurt
+		l.define(e)
+	case parser.MemberExpression:
+		l.walk(e.Object)
+	case parser.CallExpression:
+		if e.Callee != nil {
+			l.walk(e.Callee)
+		}
+
+		switch e.Call {
+		case "Def", "Class", "Let", "LetStar":
+			// The last argument of `Let` is the body, but the body is not a name anyway
+			for _, a := range e.Args {
+				l.define(a)
+			}
+			return
+		case "Args":
+			for _, param := range e.Args {
+				if p, ok := param.(parser.CallExpression); ok && (p.Call == "Rest" || p.Call == "HashMap") && len(p.Args) > 0 {
+					param = p.Args[0]
+				}
+				l.define(param)
+			}
+			return
+		case "ImportFrom":
+			for _, a := range e.Args {
+				if name, ok := a.(parser.LiteralStringExpression); ok {
+					l.defined[name.Value] = struct{}{}
+				}
+			}
+			return
+		}
+
+		for _, a := range e.Args {
+			l.walk(a)
+		}
+	}
+}
+
+// check - reports issues of the expression and everything nested in it.
+func (l *linter) check(e parser.Expression) {
+	switch e := e.(type) {
+	case parser.AssignmentExpression:
+		l.check(e.Lhs)
+		l.check(e.Rhs)
+	case parser.MemberExpression:
+		l.check(e.Object)
+	case parser.CallExpression:
+		l.checkCall(e)
+
+		if e.Callee != nil {
+			l.check(e.Callee)
+		}
+		for _, a := range e.Args {
+			l.check(a)
+		}
+	}
+}
+
+func (l *linter) checkCall(e parser.CallExpression) {
+	switch e.Call {
+	case "":
+		// Method calls can't be checked without types
+	case "Cond":
+		if len(e.Args) < 3 || len(e.Args)%2 == 0 {
+			l.report(e, SeverityWarning, "Cond requires an odd number of arguments, at least three, given %d", len(e.Args))
+		}
+	case "Def":
+		if len(e.Args) == 0 {
+			l.report(e, SeverityWarning, "Def without a name")
+		} else if name, ok := e.Args[0].(parser.VariableReferenceExpression); ok && len(e.Args) < 3 {
+			l.report(e, SeverityWarning, "Def of %s has no body", name.Value)
+		}
+	case "HashMap":
+		// A later pair silently overwrites an earlier one, which is usually a copy-paste bug
+		seen := make(map[string]struct{})
+		for i := 0; i+1 < len(e.Args); i += 2 {
+			key, ok := literalKey(e.Args[i])
+			if !ok {
+				continue
+			}
+
+			if _, ok := seen[key]; ok {
+				l.report(e.Args[i], SeverityWarning, "duplicate key %s in HashMap", key)
+			}
+			seen[key] = struct{}{}
+		}
+	case "Let":
+		if len(e.Args) == 0 {
+			return
+		}
+
+		body := e.Args[len(e.Args)-1]
+		for _, binding := range e.Args[:len(e.Args)-1] {
+			name, ok := binding.(parser.VariableReferenceExpression)
+			if a, isAssignment := binding.(parser.AssignmentExpression); isAssignment {
+				name, ok = a.Lhs.(parser.VariableReferenceExpression)
+			}
+
+			if ok && !uses(body, name.Value) {
+				l.report(binding, SeverityHint, "Let binding %s is never used", name.Value)
+			}
+		}
+	default:
+		if parser.IsBuiltin(e.Call) || python.IsBuiltin(e.Call) {
+			return
+		}
+
+		if _, ok := l.defined[e.Call]; !ok {
+			l.report(e, SeverityWarning, "unknown function %s", e.Call)
+		}
+	}
+}
+
+// literalKey - returns the literal as it is written in the source,
+// so keys of different types, like `1` and `"1"`, are different.
+func literalKey(e parser.Expression) (string, bool) {
+	switch e := e.(type) {
+	case parser.LiteralNumberExpression:
+		return e.Value, true
+	case parser.LiteralStringExpression:
+		return fmt.Sprintf("\"%s\"", e.Value), true
+	case parser.LiteralCharExpression:
+		return fmt.Sprintf("'%s'", e.Value), true
+	}
+
+	return "", false
+}
+
+// uses - tells whether the name is referenced or called anywhere in the expression.
+// Shadowing is not taken into account, so a shadowed name counts as used.
+func uses(e parser.Expression, name string) bool {
+	switch e := e.(type) {
+	case parser.VariableReferenceExpression:
+		return e.Value == name
+	case parser.AssignmentExpression:
+		return uses(e.Lhs, name) || uses(e.Rhs, name)
+	case parser.MemberExpression:
+		return uses(e.Object, name)
+	case parser.TupleExpression:
+		for _, element := range e.Elements {
+			if uses(element, name) {
+				return true
+			}
+		}
+	case parser.CallExpression:
+		if e.Call == name || (e.Callee != nil && uses(e.Callee, name)) {
+			return true
+		}
+
+		for _, a := range e.Args {
+			if uses(a, name) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
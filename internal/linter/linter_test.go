@@ -0,0 +1,65 @@
+package linter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fuale/eicg/internal/lexer"
+	"github.com/fuale/eicg/internal/parser"
+)
+
+// lint - parses the source and returns its diagnostics as strings.
+func lint(t *testing.T, src string) []string {
+	t.Helper()
+
+	ast, err := parser.New(lexer.New(strings.NewReader(src))).Parse()
+	if err != nil {
+		t.Fatalf("parsing %q: %s", src, err)
+	}
+
+	diagnostics := make([]string, 0)
+	for _, d := range Lint(ast) {
+		diagnostics = append(diagnostics, d.String())
+	}
+
+	return diagnostics
+}
+
+func TestLint(t *testing.T) {
+	src := `Def[F, Args[x]]
+Print[Cond[x, 1]]
+Print[Let[y = 1, 2]]
+Print[HashMap["a", 1, "a", 2]]
+Print[Undefined[1]]
+`
+	want := []string{
+		":1:1: warning: Def of F has no body",
+		":2:7: warning: Cond requires an odd number of arguments, at least three, given 2",
+		":3:11: hint: Let binding y is never used",
+		":4:23: warning: duplicate key \"a\" in HashMap",
+		":5:7: warning: unknown function Undefined",
+	}
+
+	got := lint(t, src)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestKnownFunctions(t *testing.T) {
+	for _, src := range []string{
+		// Defined anywhere in the program, even later
+		"Print[Square[2]]\nDef[Square, Args[x], Pow[x, 2]]",
+		// Builtins of eicg and python
+		"Print[Map[len, List[\"a\"]], len[\"abc\"], sorted[List[2, 1]], abs[1]]",
+		"Print[int[\"1\"], str[1], isinstance[1, int]]",
+		// Imported names
+		"ImportFrom[\"math\", \"sqrt\"]\nPrint[sqrt[4]]",
+		// Method calls can't be checked
+		"xs.append[1]",
+	} {
+		if got := lint(t, src); len(got) != 0 {
+			t.Errorf("%q: got %v", src, got)
+		}
+	}
+}
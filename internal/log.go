@@ -6,6 +6,7 @@ import (
 	"strings"
 )
 
+// DebugBlock - prints a titled block of debug output, like the AST of a call.
 func DebugBlock(title any, value any) (n int, err error) {
 	delim := strings.Repeat("-", 12)
 	return fmt.Fprintf(os.Stdout, "%s %s %s\n%s\n", delim, title, delim, value)
@@ -0,0 +1,97 @@
+package optimizer
+
+import (
+	"github.com/fuale/eicg/internal/parser"
+)
+
+// pureBuiltins - are builtins which only compute a value from their arguments.
+// `Div` is not here, because division by zero raises.
+var pureBuiltins = map[string]struct{}{
+	"Add":     {},
+	"Sub":     {},
+	"Mul":     {},
+	"Eq":      {},
+	"Ne":      {},
+	"List":    {},
+	"Set":     {},
+	"HashMap": {},
+}
+
+// EliminateDeadCode - drops top-level expressions which have no side effects,
+// because their result is never used, e.g. a bare `Add[1, 2]`.
+//
+// It is conservative: an expression is dropped only when it is a literal,
+// or a pure builtin call over such expressions. Anything else, like `Print`,
+// assignments, `Def` or calls to user functions, is kept. A builtin redefined
+// by the program with `Def` is not considered pure anymore.
+func EliminateDeadCode(s parser.Statement) parser.Statement {
+	block, ok := s.(parser.BlockStatement)
+	if !ok {
+		return s
+	}
+
+	defined := definedNames(block)
+
+	expressions := make([]parser.Expression, 0, len(block.Expressions))
+	for _, e := range block.Expressions {
+		if !isPure(e, defined) {
+			expressions = append(expressions, e)
+		}
+	}
+
+	block.Expressions = expressions
+	return block
+}
+
+// definedNames - collects names defined with `Def` at the top level.
+func definedNames(block parser.BlockStatement) map[string]struct{} {
+	defined := make(map[string]struct{})
+	for _, e := range block.Expressions {
+		c, ok := e.(parser.CallExpression)
+		if !ok || c.Call != "Def" || len(c.Args) == 0 {
+			continue
+		}
+
+		switch name := c.Args[0].(type) {
+		case parser.VariableReferenceExpression:
+			defined[name.Value] = struct{}{}
+		case parser.AssignmentExpression:
+			if v, ok := name.Lhs.(parser.VariableReferenceExpression); ok {
+				defined[v.Value] = struct{}{}
+			}
+		}
+	}
+
+	return defined
+}
+
+// isPure - tells whether evaluating the expression surely has no side effects.
+func isPure(e parser.Expression, defined map[string]struct{}) bool {
+	switch e := e.(type) {
+	case parser.LiteralNumberExpression, parser.LiteralStringExpression, parser.LiteralCharExpression:
+		return true
+	case parser.CallExpression:
+		if e.Callee != nil {
+			return false
+		}
+
+		if _, ok := pureBuiltins[e.Call]; !ok {
+			return false
+		}
+
+		if _, ok := defined[e.Call]; ok {
+			return false
+		}
+
+		for _, a := range e.Args {
+			if !isPure(a, defined) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	// Variables, assignments, members and anything unknown are kept
+	return false
+}
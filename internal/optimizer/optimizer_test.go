@@ -0,0 +1,61 @@
+package optimizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fuale/eicg/internal/lexer"
+	"github.com/fuale/eicg/internal/parser"
+)
+
+// parse - parses the source, failing the test on error.
+func parse(t *testing.T, src string) parser.Statement {
+	t.Helper()
+
+	ast, err := parser.New(lexer.New(strings.NewReader(src))).Parse()
+	if err != nil {
+		t.Fatalf("parsing %q: %s", src, err)
+	}
+
+	return ast
+}
+
+func TestEliminateDeadCode(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		// Pure expressions are removed
+		{"Add[1, 2]", ""},
+		{"Add[1, Mul[2, 3]]\nList[\"a\", 'b']\nHashMap[]", ""},
+		{"Print[1]\nAdd[1, 2]\nPrint[2]", "Print[1]\nPrint[2]"},
+
+		// Anything with side effects, or which may have them, is kept
+		{"Print[Add[1, 2]]", "Print[Add[1, 2]]"},
+		{"Add[1, Print[2]]", "Add[1, Print[2]]"},
+		{"Def[A = Add[1, 2]]", "Def[A = Add[1, 2]]"},
+		{"Foo[1]", "Foo[1]"},
+		{"Div[1, 0]", "Div[1, 0]"},
+		{"Add[x, 1]", "Add[x, 1]"},
+		{"xs.append[1]", "xs.append[1]"},
+
+		// Redefined builtin is a user function
+		{"Def[Add, Args[a, b], Print[a]]\nAdd[1, 2]", "Def[Add, Args[a, b], Print[a]]\nAdd[1, 2]"},
+	}
+
+	for _, tt := range tests {
+		got := EliminateDeadCode(parse(t, tt.src))
+		if !parser.EqualStatements(got, parse(t, tt.want)) {
+			t.Errorf("%q: got %#v, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+// TestEliminateDeadCodeKeepsInput - the pass returns a new block and doesn't change the input.
+func TestEliminateDeadCodeKeepsInput(t *testing.T) {
+	ast := parse(t, "Add[1, 2]\nPrint[1]")
+	EliminateDeadCode(ast)
+
+	if !parser.EqualStatements(ast, parse(t, "Add[1, 2]\nPrint[1]")) {
+		t.Error("input is changed")
+	}
+}
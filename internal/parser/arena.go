@@ -0,0 +1,63 @@
+package parser
+
+// arenaChunkSize - is the number of arguments in one chunk of the arena.
+const arenaChunkSize = 4096
+
+// Arena - is an optional memory for argument lists of parsed calls.
+// Parsing a big program allocates a lot of small `Args` slices, and when
+// the same program is parsed over and over (e.g. in a watch server),
+// it makes garbage collector busy. Arena hands out argument lists from
+// large chunks, which are reused after `Reset`.
+//
+// Arena is not safe for concurrent use, and `Reset` invalidates every
+// AST parsed with it, so it must be called only when these ASTs are not
+// used anymore.
+type Arena struct {
+	chunks [][]Expression
+
+	// chunk - is the index of the chunk we are currently filling.
+	chunk int
+}
+
+func NewArena() *Arena {
+	return &Arena{}
+}
+
+// Reset - makes all chunks available again, without freeing them.
+func (a *Arena) Reset() {
+	for i, c := range a.chunks {
+		// Drop references, so nodes of previous ASTs can be collected
+		for j := range c {
+			c[j] = nil
+		}
+		a.chunks[i] = c[:0]
+	}
+
+	a.chunk = 0
+}
+
+// alloc - copies arguments into the arena and returns the copy.
+func (a *Arena) alloc(args []Expression) []Expression {
+	n := len(args)
+
+	// Don't waste chunks on huge argument lists
+	if n > arenaChunkSize {
+		return append(make([]Expression, 0, n), args...)
+	}
+
+	for a.chunk < len(a.chunks) && cap(a.chunks[a.chunk])-len(a.chunks[a.chunk]) < n {
+		a.chunk++
+	}
+
+	if a.chunk == len(a.chunks) {
+		a.chunks = append(a.chunks, make([]Expression, 0, arenaChunkSize))
+	}
+
+	c := a.chunks[a.chunk]
+	start := len(c)
+	c = append(c, args...)
+	a.chunks[a.chunk] = c
+
+	// Limit capacity, so appending to the returned slice can't overwrite neighbours
+	return c[start:len(c):len(c)]
+}
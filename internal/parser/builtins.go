@@ -0,0 +1,126 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrReservedName = errors.New("reserved name")
+
+// Builtins - are names with a special meaning to printers. Using them as names
+// of variables or parameters shadows the builtin in some backends and
+// silently breaks in others, so it is rejected in strict mode.
+var Builtins = map[string]struct{}{
+	"Apply":      {},
+	"Args":       {},
+	"Assoc":      {},
+	"AssocIn":    {},
+	"At":         {},
+	"Call":       {},
+	"Class":      {},
+	"Compose":    {},
+	"Cond":       {},
+	"Def":        {},
+	"Do":         {},
+	"Enumerate":  {},
+	"Eq":         {},
+	"Fmt":        {},
+	"Get":        {},
+	"GetIn":      {},
+	"Has":        {},
+	"HashMap":    {},
+	"Import":     {},
+	"ImportFrom": {},
+	"In":         {},
+	"Inc":        {},
+	"Keys":       {},
+	"Let":        {},
+	"LetStar":    {},
+	"List":       {},
+	"Map":        {},
+	"Match":      {},
+	"Merge":      {},
+	"Methods":    {},
+	"Mod":        {},
+	"Ne":         {},
+	"Partial":    {},
+	"Path":       {},
+	"Pow":        {},
+	"Print":      {},
+	"Raise":      {},
+	"Rest":       {},
+	"Return":     {},
+	"Set":        {},
+	"Slice":      {},
+	"Sort":       {},
+	"Thread":     {},
+	"Try":        {},
+	"Update":     {},
+	"Values":     {},
+	"Zip":        {},
+}
+
+// IsBuiltin - tells whether the name is one of `Builtins`.
+func IsBuiltin(name string) bool {
+	_, ok := Builtins[name]
+	return ok
+}
+
+// checkNames - ensures that the call doesn't bind a builtin name, e.g. `Def[Let, ...]`
+// or `Args[Map]`. Only names bound by the call itself are checked, nested calls
+// are checked when they are parsed.
+func checkNames(c CallExpression) error {
+	switch c.Call {
+	case "Def", "Class":
+		if len(c.Args) > 0 {
+			return checkBinding(c.Args[0])
+		}
+	case "Args":
+		for _, param := range c.Args {
+			// `Rest[name]` and `HashMap[name]` are parameters too
+			if p, ok := param.(CallExpression); ok && (p.Call == "Rest" || p.Call == "HashMap") && len(p.Args) > 0 {
+				param = p.Args[0]
+			}
+
+			if err := checkBinding(param); err != nil {
+				return err
+			}
+		}
+	case "Let", "LetStar", "Do":
+		// The last argument of `Let` is the body, but `Do` may assign anywhere
+		for _, binding := range c.Args {
+			if a, ok := binding.(AssignmentExpression); ok {
+				if err := checkBinding(a); err != nil {
+					return err
+				}
+			} else if v, ok := binding.(VariableReferenceExpression); ok && c.Call == "Let" {
+				if err := checkBinding(v); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkBinding - ensures that the name being bound is not a builtin.
+// Assignments and destructuring are checked by their targets.
+func checkBinding(e Expression) error {
+	switch e := e.(type) {
+	case VariableReferenceExpression:
+		if IsBuiltin(e.Value) {
+			return fmt.Errorf("%w: %s can't be used as a name at %s", ErrReservedName, e.Value, e.Location.String())
+		}
+	case AssignmentExpression:
+		return checkBinding(e.Lhs)
+	case TupleExpression:
+		for _, element := range e.Elements {
+			if err := checkBinding(element); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package parser
+
+// Clone - returns a deep copy of the statement, which shares no slices with it,
+// so a transformation pass may change the copy and keep the input intact.
+// It also detaches argument lists from the `Arena`, which may be reset afterwards.
+func Clone(s Statement) Statement {
+	switch s := s.(type) {
+	case BlockStatement:
+		return BlockStatement{Expressions: cloneAll(s.Expressions)}
+	}
+
+	return s
+}
+
+// CloneExpression - is `Clone` for expressions.
+func CloneExpression(e Expression) Expression {
+	switch e := e.(type) {
+	case CallExpression:
+		e.Args = cloneAll(e.Args)
+		if e.Callee != nil {
+			e.Callee = CloneExpression(e.Callee)
+		}
+		return e
+	case AssignmentExpression:
+		e.Lhs = CloneExpression(e.Lhs)
+		e.Rhs = CloneExpression(e.Rhs)
+		return e
+	case MemberExpression:
+		e.Object = CloneExpression(e.Object)
+		return e
+	case TupleExpression:
+		e.Elements = cloneAll(e.Elements)
+		return e
+	}
+
+	// Names, literals and comments are values without references, so they are copied already
+	return e
+}
+
+// cloneAll - clones expressions into a new slice. Nil stays nil.
+func cloneAll(expressions []Expression) []Expression {
+	if expressions == nil {
+		return nil
+	}
+
+	cloned := make([]Expression, len(expressions))
+	for i, e := range expressions {
+		cloned[i] = CloneExpression(e)
+	}
+
+	return cloned
+}
@@ -0,0 +1,69 @@
+package parser
+
+// Equal - tells whether two expressions have the same structure and values.
+// Locations are ignored, so the same program formatted differently gives equal ASTs.
+// Nil equals only nil.
+func Equal(a, b Expression) bool {
+	switch a := a.(type) {
+	case nil:
+		return b == nil
+	case VariableReferenceExpression:
+		b, ok := b.(VariableReferenceExpression)
+		return ok && a.Value == b.Value
+	case LiteralNumberExpression:
+		b, ok := b.(LiteralNumberExpression)
+		return ok && a.Value == b.Value
+	case LiteralStringExpression:
+		b, ok := b.(LiteralStringExpression)
+		return ok && a.Value == b.Value
+	case LiteralCharExpression:
+		b, ok := b.(LiteralCharExpression)
+		return ok && a.Value == b.Value
+	case CommentExpression:
+		b, ok := b.(CommentExpression)
+		return ok && a.Text == b.Text
+	case CallExpression:
+		b, ok := b.(CallExpression)
+		return ok && a.Call == b.Call && Equal(a.Callee, b.Callee) && equalAll(a.Args, b.Args)
+	case AssignmentExpression:
+		b, ok := b.(AssignmentExpression)
+		return ok && Equal(a.Lhs, b.Lhs) && Equal(a.Rhs, b.Rhs)
+	case MemberExpression:
+		b, ok := b.(MemberExpression)
+		return ok && a.Property == b.Property && Equal(a.Object, b.Object)
+	case TupleExpression:
+		b, ok := b.(TupleExpression)
+		return ok && equalAll(a.Elements, b.Elements)
+	}
+
+	// Unknown expression types can't be compared
+	return false
+}
+
+// EqualStatements - is `Equal` for statements.
+func EqualStatements(a, b Statement) bool {
+	switch a := a.(type) {
+	case nil:
+		return b == nil
+	case BlockStatement:
+		b, ok := b.(BlockStatement)
+		return ok && equalAll(a.Expressions, b.Expressions)
+	}
+
+	return false
+}
+
+// equalAll - compares expressions pairwise. Nil and empty lists are equal.
+func equalAll(a, b []Expression) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !Equal(a[i], b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
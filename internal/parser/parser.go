@@ -1,10 +1,10 @@
 package parser
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/fuale/eicg/internal"
@@ -13,6 +13,13 @@ import (
 
 var ErrTokenNotExpected = errors.New("token not expected")
 
+var ErrUnclosedBracket = errors.New("unclosed bracket")
+
+var ErrTooManyTokens = errors.New("too many tokens")
+
+// ErrInternal - is a bug of the compiler, rather than of the program, e.g. a recovered panic.
+var ErrInternal = errors.New("internal error")
+
 // Parser - is a deeply recursive algorithm that
 // parses a stream of tokens into a tree structure.
 // Basically, it turns
@@ -24,6 +31,39 @@ var ErrTokenNotExpected = errors.New("token not expected")
 //	CallExpr { Call: x, Args: [] }
 type Parser struct {
 	lexer *lexer.Lexer
+
+	// Arena - when set, argument lists are allocated from it, see `Arena`.
+	// By default every argument list is a separate allocation.
+	Arena *Arena
+
+	// Strict - rejects programs, which are accepted by default, though probably wrong:
+	//  - builtin names used as names of variables or parameters,
+	//    e.g. `Def[F, Args[Map], ...]`, with `ErrReservedName`;
+	//  - missing brackets, e.g. `Foo[1, 2` at the end of file, with `ErrUnclosedBracket`.
+	Strict bool
+
+	// MaxTokens - limits the number of tokens in the source, parsing fails with `ErrTooManyTokens`
+	// when there are more. It protects a hosted compiler from huge inputs, together with
+	// `lexer.Lexer.MaxBytes`. Zero means no limit.
+	MaxTokens int
+
+	// Debug - prints AST of every top-level call. Dump is expensive,
+	// so it is built only when it is printed.
+	Debug bool
+
+	// consumed - is the number of consumed tokens, counted for `MaxTokens`.
+	consumed int
+
+	// args - is a stack of arguments being parsed. Nested calls push their arguments
+	// on top and pop them when done, so one array serves all argument lists.
+	args []Expression
+
+	// location - is the location of the last seen token, reported with recovered panics.
+	location Location
+
+	// tokens - are consumed tokens, recorded only by `ParseTokens`, when `record` is set.
+	tokens []lexer.Token
+	record bool
 }
 
 func New(lexer *lexer.Lexer) *Parser {
@@ -34,27 +74,93 @@ func New(lexer *lexer.Lexer) *Parser {
 
 // Main function. Here we create BlockStatement as top level node,
 // and then parse calls (only calls allowed in top level in this implementation) one by one.
-func (p *Parser) Parse() Statement {
+// Source without calls, e.g. empty or only with comments, gives an empty block, not an error.
+func (p *Parser) Parse() (Statement, error) {
+	return p.ParseContext(context.Background())
+}
+
+// ParseTokens - is like `Parse`, but also returns every consumed token in source order,
+// including comments, if lexer keeps them. Tokens are recorded while parsing,
+// so editors can highlight the source in line with the AST without lexing it twice.
+// On error, tokens consumed before the error are returned.
+func (p *Parser) ParseTokens() (Statement, []lexer.Token, error) {
+	p.record = true
+	p.tokens = make([]lexer.Token, 0)
+	defer func() { p.record = false }()
+
+	ast, err := p.Parse()
+	return ast, p.tokens, err
+}
+
+// ParseContext - is like `Parse`, but stops with `ctx.Err()` as soon as the context
+// is cancelled. Context is checked before every top-level call, for a finer
+// granularity give lexer the same context with `lexer.NewContext`.
+//
+// A panic while parsing is a bug, but it is returned as `ErrInternal`
+// with the location of the last seen token, rather than crashing the caller.
+func (p *Parser) ParseContext(ctx context.Context) (statement Statement, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			statement, err = nil, fmt.Errorf("%w: %v near %s", ErrInternal, r, p.location.String())
+		}
+	}()
+
 	block := BlockStatement{
 		Expressions: make([]Expression, 0),
 	}
 
 	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// Top-level comments are kept in place, so they can be printed before the next call
+		token, err := p.lexer.Peek(1)
+		if err == nil && token.Typ == lexer.TokenComment {
+			p.consume()
+			block.Expressions = append(block.Expressions, CommentExpression{
+				Text:     token.Value,
+				Location: token.Location,
+			})
+			continue
+		}
+
+		// Semicolon optionally separates top-level calls: `Print[1]; Print[2]`
+		if err == nil && token.Typ == lexer.TokenSemicolon {
+			p.consume()
+			continue
+		}
+
+		// Without this `42` would be reported as "expected name", which doesn't tell much
+		if err == nil && token.Typ != lexer.TokenName {
+			return nil, fmt.Errorf(
+				"%w: only calls are allowed at the top level, like Print[...], given %s at %s",
+				ErrTokenNotExpected, token.Typ.String(), token.Location.String(),
+			)
+		}
+
 		// Here we first calling recursive function to parse a function call.
 		// parse<Something> functions usually calls each other and stops when no tokens left.
-		e, err := p.parseCall()
+		var e Expression
+		if _, next, _ := p.lexer.Peek2(); err == nil && next.Typ == lexer.TokenDot {
+			e, err = p.parseMethodCall()
+		} else {
+			e, err = p.parseCall()
+		}
 		if err == io.EOF {
 			// Gracefully handle EOF
 			break
 		} else if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 
-		internal.DebugBlock("AST", spew.Sdump(e))
+		if p.Debug {
+			internal.DebugBlock("AST", spew.Sdump(e))
+		}
 		block.Expressions = append(block.Expressions, e)
 	}
 
-	return block
+	return block, nil
 }
 
 // parseCall - for example, tries to parse a function call. :^)
@@ -66,19 +172,39 @@ func (p *Parser) parseCall() (Expression, error) {
 		return nil, err
 	}
 
-	_, _ = p.expectToken(lexer.TokenSquareBracketOpen)
+	open, err := p.expectToken(lexer.TokenSquareBracketOpen)
+	if err != nil && p.Strict {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	// Statements of `Do` may assign several names at once
+	args, err := p.parseArgs(called.Value == "Do")
+	if err != nil {
+		return nil, p.unclosed(open.Location, err)
+	}
 
-	args, err := p.parseArgs()
+	end, err := p.closeBracket(open.Location)
 	if err != nil {
 		return nil, err
 	}
 
-	_, _ = p.expectToken(lexer.TokenSquareBracketClose)
+	call := CallExpression{
+		Call:     called.Value,
+		Args:     args,
+		Location: called.Location,
+		End:      end,
+	}
 
-	return CallExpression{
-		Call: called.Value,
-		Args: args,
-	}, nil
+	if p.Strict {
+		if err := checkNames(call); err != nil {
+			return nil, err
+		}
+	}
+
+	return call, nil
 }
 
 func (p *Parser) parseAssignment() (Expression, error) {
@@ -96,85 +222,367 @@ func (p *Parser) parseAssignment() (Expression, error) {
 
 	return AssignmentExpression{
 		Lhs: VariableReferenceExpression{
-			Value: lhs.Value,
+			Value:    lhs.Value,
+			Location: lhs.Location,
 		},
-		Rhs: rhs,
+		Rhs:      rhs,
+		Location: lhs.Location,
 	}, nil
 }
 
-// I think, parseExpression is a the most difficult to program function,
-// because there is many conditions and recursive calls
+// parseExpression - parses a primary expression and then any infix
+// comparisons which follows it. Comparisons are just a sugar, so `x == 1`
+// lowers to the same AST as `Eq[x, 1]`.
 func (p *Parser) parseExpression() (Expression, error) {
-	token, err := p.lexer.Peek(1)
+	lhs, err := p.parseMember()
 	if err != nil {
 		return nil, err
 	}
 
+	// Comparisons are left-associative: `a == b == c` is `Eq[Eq[a, b], c]`
+	for {
+		token, err := p.peek()
+		if err != nil || token.Typ != lexer.TokenEqualEqual {
+			break
+		}
+
+		p.consume()
+
+		rhs, err := p.parseMember()
+		if err != nil {
+			return nil, err
+		}
+
+		lhs = CallExpression{
+			Call:     "Eq",
+			Args:     []Expression{lhs, rhs},
+			Location: LocationOf(lhs),
+		}
+	}
+
+	return lhs, nil
+}
+
+// parseMember - parses a primary expression followed by any number of
+// attribute accesses and method calls, so `a.b.c[x]` is `((a.b).c)[x]`.
+func (p *Parser) parseMember() (Expression, error) {
+	object, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		token, err := p.peek()
+		if err != nil || token.Typ != lexer.TokenDot {
+			break
+		}
+
+		p.consume()
+
+		property, err := p.expectToken(lexer.TokenName)
+		if err != nil {
+			return nil, err
+		}
+
+		object = MemberExpression{
+			Object:   object,
+			Property: property.Value,
+			Location: LocationOf(object),
+		}
+
+		// Method call: `obj.method[args]`
+		if t, err := p.peek(); err == nil && t.Typ == lexer.TokenSquareBracketOpen {
+			p.consume()
+
+			args, err := p.parseArgs(false)
+			if err != nil {
+				return nil, p.unclosed(t.Location, err)
+			}
+
+			end, err := p.closeBracket(t.Location)
+			if err != nil {
+				return nil, err
+			}
+
+			object = CallExpression{
+				Callee:   object,
+				Args:     args,
+				Location: LocationOf(object),
+				End:      end,
+			}
+		}
+	}
+
+	return object, nil
+}
+
+// parseMethodCall - parses a top-level method call: `xs.append[1]`.
+// Only the call may be a statement, because a member alone does nothing.
+func (p *Parser) parseMethodCall() (Expression, error) {
+	e, err := p.parseMember()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	if call, ok := e.(CallExpression); ok && call.Callee != nil {
+		return call, nil
+	}
+
+	return nil, fmt.Errorf(
+		"%w: only calls are allowed at the top level, like xs.append[...], given a member at %s",
+		ErrTokenNotExpected, LocationOf(e).String(),
+	)
+}
+
+// I think, parsePrimary is a the most difficult to program function,
+// because there is many conditions and recursive calls
+func (p *Parser) parsePrimary() (Expression, error) {
+	token, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	// Name is a call when followed by `[`, or an assignment when followed by `=`.
+	// Assignment's right side is a whole expression, so `a = b[1]` assigns a call.
 	if token.Typ == lexer.TokenName {
-		if t, err := p.lexer.Peek(2); err == nil && t.Typ == lexer.TokenSquareBracketOpen {
+		// Comments are already skipped by `peek`, so the name is the first of the two
+		_, next, _ := p.lexer.Peek2()
+
+		if next.Typ == lexer.TokenSquareBracketOpen {
 			return p.parseCall()
 		}
 
-		if t, err := p.lexer.Peek(2); err == nil && t.Typ == lexer.TokenEquals {
+		if next.Typ == lexer.TokenEquals {
 			return p.parseAssignment()
 		}
 
-		p.lexer.Consume()
+		p.consume()
 
 		return VariableReferenceExpression{
-			Value: token.Value,
+			Value:    token.Value,
+			Location: token.Location,
 		}, nil
 	}
 
 	if token.Typ == lexer.TokenNumber {
-		p.lexer.Consume()
+		p.consume()
+
+		return LiteralNumberExpression{Value: token.Value, Location: token.Location}, nil
+	}
 
-		return LiteralNumberExpression{token.Value}, nil
+	if token.Typ == lexer.TokenString {
+		p.consume()
+
+		return LiteralStringExpression{Value: token.Value, Location: token.Location}, nil
+	}
+
+	if token.Typ == lexer.TokenChar {
+		p.consume()
+
+		return LiteralCharExpression{Value: token.Value, Location: token.Location}, nil
 	}
 
-	return nil, fmt.Errorf("failed to parse expression, token %s", spew.Sdump(token))
+	return nil, fmt.Errorf("%w: expected expression, given %s at %s", ErrTokenNotExpected, token.Typ.String(), token.Location.String())
 }
 
-func (p *Parser) parseArgs() ([]Expression, error) {
-	args := make([]Expression, 0)
+// parseArgs - parses comma-separated arguments up to the closing bracket.
+// When `destructure` is set, names joined to an assignment without spaces are its targets,
+// so `a,b = Foo[]` is a single assignment, while `a, b = Foo[]` is `a` and `b = Foo[]`.
+// It is not the default, because in other lists, e.g. `Args[a,b = 1]`, these are separate arguments.
+func (p *Parser) parseArgs(destructure bool) ([]Expression, error) {
+	// Arguments are collected on the stack, and copied out at the end
+	base := len(p.args)
+	defer func() { p.args = p.args[:base] }()
+
+	token, err := p.peek()
+	if err == io.EOF {
+		// There must be at least the closing bracket
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Empty arguments are not allowed, only a trailing comma is: `Foo[,]` and `Foo[, 1]` are errors.
+	// Whitespace is skipped by lexer, so `Foo[ ]` is just an empty call.
+	if token.Typ == lexer.TokenComma {
+		return nil, fmt.Errorf("%w: leading comma at %s", ErrTokenNotExpected, token.Location.String())
+	}
 
-	token := p.lexer.MustPeek(1)
 	if token.Typ != lexer.TokenSquareBracketClose {
 		e, err := p.parseExpression()
 		if err != nil {
 			return nil, err
 		}
 
-		args = append(args, e)
+		p.args = append(p.args, e)
 		for {
-			token, err = p.lexer.Peek(1)
+			token, err = p.peek()
+			if err == io.EOF && !p.Strict {
+				// Not strict parser closes brackets at the end of file, see `closeBracket`.
+				// Returning EOF would make the caller drop the whole call.
+				break
+			}
 			if err != nil {
 				return nil, err
 			}
 			if token.Typ == lexer.TokenComma {
-				p.lexer.Consume()
+				p.consume()
+
+				// Trailing comma: `List[1, 2,]`
+				if token, err := p.peek(); (err == nil && token.Typ == lexer.TokenSquareBracketClose) || (err == io.EOF && !p.Strict) {
+					break
+				} else if err == nil && token.Typ == lexer.TokenComma {
+					return nil, fmt.Errorf("%w: empty argument at %s", ErrTokenNotExpected, token.Location.String())
+				}
+
 				e, err := p.parseExpression()
 				if err != nil {
 					return nil, err
 				}
-				args = append(args, e)
+				if destructure {
+					e = p.collectTargets(base, e)
+				}
+				p.args = append(p.args, e)
 			} else {
 				break
 			}
 		}
 	}
 
-	return args, nil
+	if p.Arena != nil {
+		return p.Arena.alloc(p.args[base:]), nil
+	}
+
+	return append(make([]Expression, 0, len(p.args)-base), p.args[base:]...), nil
+}
+
+// collectTargets - pops names joined to the assignment `e` from the arguments stack,
+// and makes them targets of the assignment together with its own name.
+// Only names separated by a bare comma are joined, e.g. `a,b = F[]`, because a name
+// before an assignment may as well be a statement of its own: `Do[x, a = 1]`.
+func (p *Parser) collectTargets(base int, e Expression) Expression {
+	a, ok := e.(AssignmentExpression)
+	if !ok {
+		return e
+	}
+
+	next, ok := a.Lhs.(VariableReferenceExpression)
+	if !ok {
+		return e
+	}
+
+	start := len(p.args)
+	for start > base {
+		name, ok := p.args[start-1].(VariableReferenceExpression)
+		if !ok || !joined(name, next) {
+			break
+		}
+		next = name
+		start--
+	}
+
+	if start == len(p.args) {
+		return e
+	}
+
+	targets := TupleExpression{
+		Elements: append(append(make([]Expression, 0, len(p.args)-start+1), p.args[start:]...), a.Lhs),
+		Location: LocationOf(p.args[start]),
+	}
+	p.args = p.args[:start]
+
+	a.Lhs = targets
+	a.Location = targets.Location
+	return a
+}
+
+// joined - tells whether the names are separated by a single comma, without spaces: `a,b`.
+func joined(name, next VariableReferenceExpression) bool {
+	return name.Location.Row == next.Location.Row && name.Location.Col+len([]rune(name.Value))+1 == next.Location.Col
+}
+
+// peek - returns the next token, skipping comments.
+// Comments inside calls are not kept, only top-level ones, see `ParseContext`.
+func (p *Parser) peek() (lexer.Token, error) {
+	for {
+		token, err := p.lexer.Peek(1)
+		if err == nil {
+			p.location = token.Location
+
+			// Every token is peeked before it is consumed, so it is the place to stop
+			if p.MaxTokens > 0 && p.consumed >= p.MaxTokens {
+				return lexer.UnknownToken, fmt.Errorf("%w: more than %d at %s", ErrTooManyTokens, p.MaxTokens, token.Location.String())
+			}
+		}
+		if err != nil || token.Typ != lexer.TokenComment {
+			return token, err
+		}
+
+		p.consume()
+	}
+}
+
+// closeBracket - consumes the bracket, which closes the one opened at `open`, and returns its location.
+// Missing bracket is tolerated, unless parser is strict, then the location of the last seen token is returned.
+func (p *Parser) closeBracket(open Location) (Location, error) {
+	token, err := p.expectToken(lexer.TokenSquareBracketClose)
+	if err == nil {
+		return token.Location, nil
+	}
+	if !p.Strict {
+		return p.location, nil
+	}
+
+	return Location{}, p.unclosed(open, err)
+}
+
+// unclosed - turns end of file inside brackets opened at `open` into `ErrUnclosedBracket`
+// in strict mode. Otherwise EOF ends parsing gracefully, like the call was closed.
+// Error of nested brackets is kept as is, because it points to the innermost bracket.
+func (p *Parser) unclosed(open Location, err error) error {
+	if !p.Strict || errors.Is(err, ErrUnclosedBracket) {
+		return err
+	}
+
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: %w, opened at %s", ErrUnclosedBracket, io.ErrUnexpectedEOF, open.String())
+	}
+
+	return err
+}
+
+// consume - consumes the next token, which is already peeked, recording it for `ParseTokens`.
+func (p *Parser) consume() {
+	p.consumed++
+	if p.record {
+		if token, err := p.lexer.Peek(1); err == nil {
+			p.tokens = append(p.tokens, token)
+		}
+	}
+
+	p.lexer.Consume()
 }
 
 // expectToken - is a helper function that ensures that the next token is the one we expected.
 func (p *Parser) expectToken(tokenType lexer.TokenType) (token lexer.Token, err error) {
+	if _, err := p.peek(); err != nil {
+		return lexer.UnknownToken, err
+	}
+
 	token, err = p.lexer.Next()
 
 	if err != nil {
 		return lexer.UnknownToken, err
 	}
 
+	p.consumed++
+	if p.record {
+		p.tokens = append(p.tokens, token)
+	}
+
 	if token.Typ == tokenType {
 		return token, nil
 	} else {
@@ -0,0 +1,526 @@
+package parser
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fuale/eicg/internal/lexer"
+)
+
+// parse - parses the source, failing the test on error.
+func parse(t *testing.T, src string) BlockStatement {
+	t.Helper()
+
+	ast, err := New(lexer.New(strings.NewReader(src))).Parse()
+	if err != nil {
+		t.Fatalf("parsing %q: %s", src, err)
+	}
+
+	return ast.(BlockStatement)
+}
+
+// parseErr - parses the source, which must fail, and returns the error.
+func parseErr(t *testing.T, src string) error {
+	t.Helper()
+
+	_, err := New(lexer.New(strings.NewReader(src))).Parse()
+	if err == nil {
+		t.Fatalf("parsing %q: expected an error", src)
+	}
+
+	return err
+}
+
+// call, name and number - build AST nodes without locations, for comparing with `Equal`.
+func call(name string, args ...Expression) CallExpression {
+	return CallExpression{Call: name, Args: args}
+}
+
+func name(value string) VariableReferenceExpression {
+	return VariableReferenceExpression{Value: value}
+}
+
+func number(value string) LiteralNumberExpression {
+	return LiteralNumberExpression{Value: value}
+}
+
+// expectAST - parses the source and compares it with the expected top-level expressions.
+func expectAST(t *testing.T, src string, want ...Expression) {
+	t.Helper()
+
+	got := parse(t, src)
+	if !EqualStatements(got, BlockStatement{Expressions: want}) {
+		t.Errorf("%q: got %#v, want %#v", src, got.Expressions, want)
+	}
+}
+
+func TestAssignmentAndComparison(t *testing.T) {
+	expectAST(t, "Do[x = 1]", call("Do", AssignmentExpression{Lhs: name("x"), Rhs: number("1")}))
+	expectAST(t, "Print[x == 1]", call("Print", call("Eq", name("x"), number("1"))))
+
+	// Comparison on the right side of an assignment belongs to the assigned value
+	expectAST(t, "Do[x = y == 1]", call("Do", AssignmentExpression{Lhs: name("x"), Rhs: call("Eq", name("y"), number("1"))}))
+
+	// Comparisons are left-associative
+	expectAST(t, "Print[a == b == c]", call("Print", call("Eq", call("Eq", name("a"), name("b")), name("c"))))
+}
+
+func TestMember(t *testing.T) {
+	expectAST(t, "Print[obj.name]", call("Print", MemberExpression{Object: name("obj"), Property: "name"}))
+	expectAST(t, "Print[a.b.c]", call("Print", MemberExpression{Object: MemberExpression{Object: name("a"), Property: "b"}, Property: "c"}))
+	expectAST(t, "Print[List[].count]", call("Print", MemberExpression{Object: call("List"), Property: "count"}))
+
+	parseErr(t, "Print[obj.]")
+	parseErr(t, "Print[obj.1]")
+}
+
+func TestTopLevelMethodCall(t *testing.T) {
+	append := CallExpression{Callee: MemberExpression{Object: name("xs"), Property: "append"}, Args: []Expression{number("1")}}
+	expectAST(t, "xs.append[1]", append)
+	expectAST(t, "xs.append[1].pop[]", CallExpression{Callee: MemberExpression{Object: append, Property: "pop"}, Args: []Expression{}})
+
+	// A member alone does nothing, so it isn't a statement
+	for _, src := range []string{"xs.append", "xs.append[1].length", "xs."} {
+		parseErr(t, src)
+	}
+	if err := parseStrict("xs.append[1"); !errors.Is(err, ErrUnclosedBracket) {
+		t.Errorf("expected ErrUnclosedBracket, got %v", err)
+	}
+}
+
+// program - generates a program of about the given size in bytes.
+func program(size int) string {
+	line := "Def[F, Args[x, y], Print[Map[G, List[1, 2.5, \"str\", x]], Cond[x == 1, y, Do[a = 1, a]]]]\n"
+	return strings.Repeat(line, size/len(line)+1)
+}
+
+// BenchmarkParse - parses the same program over and over, like a watch server does,
+// with and without an `Arena`. Steady-state allocations are what matters here.
+func BenchmarkParse(b *testing.B) {
+	src := program(256 << 10)
+
+	b.Run("default", func(b *testing.B) {
+		b.SetBytes(int64(len(src)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := New(lexer.New(strings.NewReader(src))).Parse(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("arena", func(b *testing.B) {
+		arena := NewArena()
+		b.SetBytes(int64(len(src)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			arena.Reset()
+			ps := New(lexer.New(strings.NewReader(src)))
+			ps.Arena = arena
+			if _, err := ps.Parse(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// TestArena - AST parsed with an arena is the same, and its clone survives `Reset`.
+func TestArena(t *testing.T) {
+	src := program(4 << 10)
+	want := parse(t, src)
+
+	arena := NewArena()
+	ps := New(lexer.New(strings.NewReader(src)))
+	ps.Arena = arena
+	got, err := ps.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !EqualStatements(got, want) {
+		t.Fatal("AST parsed with an arena differs")
+	}
+
+	cloned := Clone(got)
+	arena.Reset()
+	ps = New(lexer.New(strings.NewReader("Print[1, 2, 3]")))
+	ps.Arena = arena
+	if _, err := ps.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if !EqualStatements(cloned, want) {
+		t.Error("clone is changed by reusing the arena")
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	targets := TupleExpression{Elements: []Expression{name("a"), name("b")}}
+	expectAST(t, "Do[a,b = F[]]", call("Do", AssignmentExpression{Lhs: targets, Rhs: call("F")}))
+
+	// Only names right before the assignment are its targets
+	expectAST(t, "Do[Print[1], a,b = F[], a]", call("Do", call("Print", number("1")), AssignmentExpression{Lhs: targets, Rhs: call("F")}, name("a")))
+
+	// Only names joined by a bare comma are targets, otherwise they are statements of their own
+	expectAST(t, "Do[x, a = 1]", call("Do", name("x"), AssignmentExpression{Lhs: name("a"), Rhs: number("1")}))
+	expectAST(t, "Do[x, a,b = F[]]", call("Do", name("x"), AssignmentExpression{Lhs: targets, Rhs: call("F")}))
+	expectAST(t, "Do[a,\nb = F[]]", call("Do", name("a"), AssignmentExpression{Lhs: name("b"), Rhs: call("F")}))
+	expectAST(t, "Do[a ,b = F[]]", call("Do", name("a"), AssignmentExpression{Lhs: name("b"), Rhs: call("F")}))
+
+	// Outside of `Do` assignments are keyword arguments, so names are not collected
+	expectAST(t, "F[a, b = 1]", call("F", name("a"), AssignmentExpression{Lhs: name("b"), Rhs: number("1")}))
+}
+
+func TestComments(t *testing.T) {
+	l := lexer.New(strings.NewReader("// top\nPrint[1, // inside\n2]"))
+	l.KeepComments = true
+	ast, err := New(l).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Top-level comments are kept, comments inside calls are skipped
+	want := BlockStatement{Expressions: []Expression{CommentExpression{Text: " top"}, call("Print", number("1"), number("2"))}}
+	if !EqualStatements(ast, want) {
+		t.Errorf("got %#v", ast)
+	}
+}
+
+// parseStrict - parses the source in strict mode and returns the error.
+func parseStrict(src string) error {
+	ps := New(lexer.New(strings.NewReader(src)))
+	ps.Strict = true
+	_, err := ps.Parse()
+	return err
+}
+
+func TestReservedNames(t *testing.T) {
+	for _, src := range []string{
+		"Def[F, Args[Map], Map]",
+		"Def[Let, Args[x], x]",
+		"Def[Print = 1]",
+		"Def[F, Args[Rest[List]], 1]",
+		"Print[Let[Cond = 1, Cond]]",
+		"Def[F, Args[], Do[a, Map = G[], a]]",
+	} {
+		if err := parseStrict(src); !errors.Is(err, ErrReservedName) {
+			t.Errorf("%q: expected ErrReservedName, got %v", src, err)
+		}
+
+		// Not strict parser allows them
+		parse(t, src)
+	}
+
+	// Builtins may still be called and passed as values
+	if err := parseStrict("Def[F, Args[xs], Map[Print, xs]]"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestTrailingComma(t *testing.T) {
+	expectAST(t, "Print[List[1, 2,]]", call("Print", call("List", number("1"), number("2"))))
+	expectAST(t, "Print[1,\n]", call("Print", number("1")))
+
+	for _, src := range []string{"Print[,]", "Print[1,,]", "Print[1,,2]"} {
+		if err := parseErr(t, src); !errors.Is(err, ErrTokenNotExpected) {
+			t.Errorf("%q: expected ErrTokenNotExpected, got %v", src, err)
+		}
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	for _, src := range []string{"", "  \n\t", "// comment", "// comment\n// another\n"} {
+		if got := parse(t, src); len(got.Expressions) != 0 {
+			t.Errorf("%q: got %#v", src, got.Expressions)
+		}
+	}
+}
+
+func TestTopLevelNotCall(t *testing.T) {
+	for _, src := range []string{"42", "Print[1]\n\"str\"", "'c'", "]"} {
+		err := parseErr(t, src)
+		if !errors.Is(err, ErrTokenNotExpected) || !strings.Contains(err.Error(), "only calls are allowed at the top level") {
+			t.Errorf("%q: got %v", src, err)
+		}
+	}
+
+	if err := parseErr(t, "Print[1]\n42"); !strings.HasSuffix(err.Error(), "given number at :2:1") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestAssignmentOfCall(t *testing.T) {
+	expectAST(t, "Do[a = Map[f, xs]]", call("Do", AssignmentExpression{Lhs: name("a"), Rhs: call("Map", name("f"), name("xs"))}))
+	expectAST(t, "Def[A = Map[f, xs]]", call("Def", AssignmentExpression{Lhs: name("A"), Rhs: call("Map", name("f"), name("xs"))}))
+
+	// Name followed by `[` is a call, even as an argument
+	expectAST(t, "Print[a, b[1]]", call("Print", name("a"), call("b", number("1"))))
+
+	parseErr(t, "Do[a = ]")
+}
+
+func TestSemicolons(t *testing.T) {
+	want := []Expression{call("Print", number("1")), call("Print", number("2"))}
+	expectAST(t, "Print[1]; Print[2]", want...)
+	expectAST(t, "Print[1];;Print[2];", want...)
+	expectAST(t, "Print[1]\nPrint[2]", want...)
+
+	// Separator is for top-level calls only
+	parseErr(t, "Print[1; 2]")
+}
+
+func TestUnclosedBracket(t *testing.T) {
+	for _, tt := range []struct{ src, location string }{
+		{"Foo[1, 2", ":1:4"},
+		{"Print[1]\nFoo[List[1, 2]", ":2:4"},
+		{"Foo[List[1, 2", ":1:9"},
+	} {
+		err := parseStrict(tt.src)
+		if !errors.Is(err, ErrUnclosedBracket) || !strings.Contains(err.Error(), tt.location) {
+			t.Errorf("%q: expected ErrUnclosedBracket at %s, got %v", tt.src, tt.location, err)
+		}
+	}
+
+	// Not strict parser closes brackets at the end of file, rather than dropping the call
+	expectAST(t, "Foo[1, 2", call("Foo", number("1"), number("2")))
+	expectAST(t, "Foo[1,", call("Foo", number("1")))
+	expectAST(t, "Print[1]\nFoo[List[1, 2", call("Print", number("1")), call("Foo", call("List", number("1"), number("2"))))
+	expectAST(t, "xs.append[1", CallExpression{Callee: MemberExpression{Object: name("xs"), Property: "append"}, Args: []Expression{number("1")}})
+	parseErr(t, "Foo[")
+
+	if err := parseStrict("Foo"); err == nil {
+		t.Error("call without brackets is accepted in strict mode")
+	}
+}
+
+func TestEmptyCall(t *testing.T) {
+	expectAST(t, "Foo[ ]", call("Foo"))
+	expectAST(t, "Foo[\n\t]", call("Foo"))
+	expectAST(t, "Foo[]", call("Foo"))
+
+	for _, tt := range []struct{ src, location string }{
+		{"Foo[,]", ":1:5"},
+		{"Foo[ , ]", ":1:6"},
+		{"Foo[ , 1]", ":1:6"},
+	} {
+		err := parseErr(t, tt.src)
+		if !errors.Is(err, ErrTokenNotExpected) || !strings.HasSuffix(err.Error(), "leading comma at "+tt.location) {
+			t.Errorf("%q: expected leading comma at %s, got %v", tt.src, tt.location, err)
+		}
+	}
+}
+
+// lex - lexes the source directly, without parsing.
+func lex(t *testing.T, src string, keepComments bool) []lexer.Token {
+	t.Helper()
+
+	l := lexer.New(strings.NewReader(src))
+	l.KeepComments = keepComments
+
+	tokens := make([]lexer.Token, 0)
+	for {
+		token, err := l.Next()
+		if err == io.EOF {
+			return tokens
+		}
+		if err != nil {
+			t.Fatalf("lexing %q: %s", src, err)
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+func TestParseTokens(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"Print[1]",
+		"// header\nDef[F, Args[x, y = 2], // body\n  Add[x, y]]\nPrint[F[1]]; Print['c', \"s\"]",
+		"Def[A = HashMap[]]\nA.Set[\"k\", 1.5e3]\nPrint[A.k, x == 1_000]",
+		"Print[Do[], List[1, 2,], Foo[][x]]",
+	} {
+		for _, keepComments := range []bool{false, true} {
+			l := lexer.New(strings.NewReader(src))
+			l.KeepComments = keepComments
+
+			_, tokens, err := New(l).ParseTokens()
+			if err != nil {
+				t.Fatalf("%q: %s", src, err)
+			}
+
+			if want := lex(t, src, keepComments); !reflect.DeepEqual(tokens, want) {
+				t.Errorf("%q, comments %v: got tokens\n%v\nwant\n%v", src, keepComments, tokens, want)
+			}
+		}
+	}
+}
+
+// TestUnexpectedToken - a token which can't start an expression is reported by its type and location.
+func TestUnexpectedToken(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"Print[;]", "token not expected: expected expression, given semicolon at :1:7"},
+		{"Print[1, .]", "token not expected: expected expression, given dot at :1:10"},
+		{"Print[\n  ==]", "token not expected: expected expression, given double equals sign at :2:3"},
+	}
+
+	for _, tt := range tests {
+		if err := parseErr(t, tt.src); !errors.Is(err, ErrTokenNotExpected) || err.Error() != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+// TestErrorsWithoutDump - errors name tokens plainly, instead of dumping their structs.
+func TestErrorsWithoutDump(t *testing.T) {
+	for _, src := range []string{
+		"Print[;]", "Print[1,,2]", "1", "Print[1 2]", "Print[x.]", "Print[x = ]", "Foo[]]", "Print[\"unterminated]",
+	} {
+		err := parseErr(t, src)
+		for _, dump := range []string{"lexer.Token", "Typ:", "Location:", "(string)", "(int)", "{"} {
+			if strings.Contains(err.Error(), dump) {
+				t.Errorf("%q: error contains %q: %s", src, dump, err)
+			}
+		}
+	}
+}
+
+// endless - is an infinite source, which repeats the program.
+type endless struct {
+	program string
+	read    int
+}
+
+func (e *endless) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = e.program[(e.read+i)%len(e.program)]
+	}
+	e.read += len(b)
+	return len(b), nil
+}
+
+func TestMaxTokens(t *testing.T) {
+	parseLimited := func(src io.Reader, limit int) error {
+		p := New(lexer.New(src))
+		p.MaxTokens = limit
+		_, err := p.Parse()
+		return err
+	}
+
+	// The limit is inclusive, `Print[1]` is four tokens
+	if err := parseLimited(strings.NewReader("Print[1]"), 4); err != nil {
+		t.Errorf("source of exactly MaxTokens: %s", err)
+	}
+	err := parseLimited(strings.NewReader("Print[1]"), 3)
+	if !errors.Is(err, ErrTooManyTokens) || !strings.HasSuffix(err.Error(), "at :1:8") {
+		t.Errorf("expected ErrTooManyTokens at the fourth token, got %v", err)
+	}
+
+	// An endless source stops at the limit, instead of being parsed forever
+	if err := parseLimited(&endless{program: "Print[1]\n"}, 1000); !errors.Is(err, ErrTooManyTokens) {
+		t.Errorf("expected ErrTooManyTokens, got %v", err)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	at := func(row, col int) Location { return Location{Row: row, Col: col} }
+	member := func(object Expression, property string) MemberExpression {
+		return MemberExpression{Object: object, Property: property}
+	}
+
+	equal := []struct{ a, b Expression }{
+		{nil, nil},
+		{name("x"), VariableReferenceExpression{Value: "x", Location: at(2, 3)}},
+		{call("F", number("1")), CallExpression{Call: "F", Args: []Expression{LiteralNumberExpression{Value: "1", Location: at(1, 3)}}, Location: at(1, 1), End: at(1, 4)}},
+		{call("F"), CallExpression{Call: "F", Args: []Expression{}}},
+		{CallExpression{Callee: member(name("a"), "b")}, CallExpression{Callee: member(name("a"), "b")}},
+		{AssignmentExpression{Lhs: name("x"), Rhs: number("1")}, AssignmentExpression{Lhs: name("x"), Rhs: number("1"), Location: at(1, 3)}},
+		{TupleExpression{Elements: []Expression{name("a")}}, TupleExpression{Elements: []Expression{name("a")}}},
+		{CommentExpression{Text: " c"}, CommentExpression{Text: " c", Location: at(5, 1)}},
+		{LiteralStringExpression{Value: "s"}, LiteralStringExpression{Value: "s"}},
+		{LiteralCharExpression{Value: "c"}, LiteralCharExpression{Value: "c"}},
+	}
+	for _, tt := range equal {
+		if !Equal(tt.a, tt.b) || !Equal(tt.b, tt.a) {
+			t.Errorf("expected equal: %#v and %#v", tt.a, tt.b)
+		}
+	}
+
+	different := []struct{ a, b Expression }{
+		{nil, name("x")},
+		{name("x"), name("y")},
+		{name("x"), LiteralStringExpression{Value: "x"}},
+		{LiteralStringExpression{Value: "c"}, LiteralCharExpression{Value: "c"}},
+		{number("1"), number("1.0")},
+		{call("F", number("1")), call("F")},
+		{call("F", number("1")), call("G", number("1"))},
+		{call("F", number("1"), number("2")), call("F", number("2"), number("1"))},
+		{CallExpression{Callee: member(name("a"), "b")}, CallExpression{Callee: member(name("a"), "c")}},
+		{CallExpression{Callee: member(name("a"), "b")}, call("")},
+		{AssignmentExpression{Lhs: name("x"), Rhs: number("1")}, AssignmentExpression{Lhs: name("x"), Rhs: number("2")}},
+		{TupleExpression{Elements: []Expression{name("a")}}, TupleExpression{Elements: []Expression{name("a"), name("b")}}},
+		{CommentExpression{Text: " c"}, CommentExpression{Text: "c"}},
+	}
+	for _, tt := range different {
+		if Equal(tt.a, tt.b) || Equal(tt.b, tt.a) {
+			t.Errorf("expected different: %#v and %#v", tt.a, tt.b)
+		}
+	}
+
+	// The same program formatted differently
+	a, b := parse(t, "Def[F, Args[x], Add[x, 1]]"), parse(t, "Def[\n  F,\n  Args[x],\n  Add[x, 1],\n]")
+	if !EqualStatements(a, b) {
+		t.Errorf("expected equal ASTs: %#v and %#v", a, b)
+	}
+	if EqualStatements(a, parse(t, "Def[F, Args[x], Add[x, 2]]")) || EqualStatements(a, nil) || !EqualStatements(nil, nil) {
+		t.Error("different statements are equal")
+	}
+	if !EqualStatements(BlockStatement{}, BlockStatement{Expressions: []Expression{}}) {
+		t.Error("nil and empty blocks are different")
+	}
+}
+
+// TestClone - changing a clone through its slices, at any depth, leaves the original intact.
+func TestClone(t *testing.T) {
+	src := "Def[F, Args[x, y = List[1]], Do[a,b = G[x], Add[a, b]]]\nPrint[F[1].g[H[2]]]\nPrint[]"
+	original := parse(t, src)
+	cloned := Clone(original).(BlockStatement)
+
+	if !EqualStatements(cloned, original) {
+		t.Fatalf("clone differs: %#v", cloned)
+	}
+
+	mutate := []func(){
+		func() { cloned.Expressions[2] = name("changed") },
+		func() { cloned.Expressions[0].(CallExpression).Args[0] = name("changed") },
+		// Default value of `y` in `Args`
+		func() {
+			params := cloned.Expressions[0].(CallExpression).Args[1].(CallExpression)
+			params.Args[1].(AssignmentExpression).Rhs.(CallExpression).Args[0] = number("2")
+		},
+		// Targets of the destructuring
+		func() {
+			body := cloned.Expressions[0].(CallExpression).Args[2].(CallExpression)
+			body.Args[0].(AssignmentExpression).Lhs.(TupleExpression).Elements[0] = name("changed")
+		},
+		// Arguments of the call the method is called on, and of the method
+		func() {
+			method := cloned.Expressions[1].(CallExpression).Args[0].(CallExpression)
+			method.Callee.(MemberExpression).Object.(CallExpression).Args[0] = number("2")
+			method.Args[0].(CallExpression).Args[0] = number("3")
+		},
+	}
+
+	for i, f := range mutate {
+		f()
+		if !EqualStatements(original, parse(t, src)) {
+			t.Fatalf("mutation %d changed the original: %#v", i, original)
+		}
+	}
+	if EqualStatements(cloned, original) {
+		t.Error("clone is not changed")
+	}
+
+	if Clone(nil) != nil || CloneExpression(nil) != nil {
+		t.Error("clone of nil is not nil")
+	}
+}
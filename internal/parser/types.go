@@ -1,5 +1,11 @@
 package parser
 
+import "github.com/fuale/eicg/internal/lexer"
+
+// Location - is a position in the source, where the expression starts.
+// It is the same as token location, aliased for convenience.
+type Location = lexer.Location
+
 // Go's type system not allowing using interface{}
 // because then we can pass any value to a methods.
 type Expression interface {
@@ -14,12 +20,28 @@ type Statement interface {
 
 // Expression, that references a variable
 type VariableReferenceExpression struct {
-	Value string
+	Value    string
+	Location Location
 }
 
 // Expression, that represent literal number
 type LiteralNumberExpression struct {
-	Value string
+	Value    string
+	Location Location
+}
+
+// Expression, that represent literal string.
+// Value is the raw contents between quotes, including escape sequences.
+type LiteralStringExpression struct {
+	Value    string
+	Location Location
+}
+
+// Expression, that represent literal char: `'a'`.
+// Value is a single rune, or an escape sequence, like `\n`.
+type LiteralCharExpression struct {
+	Value    string
+	Location Location
 }
 
 // Expression, that represents a function call
@@ -29,19 +51,84 @@ type CallExpression struct {
 
 	// Function name
 	Call string
+
+	// Callee - is the called expression, when it is not a plain name,
+	// e.g. a method `obj.method[args]`. In that case `Call` is empty.
+	Callee Expression
+
+	Location Location
+
+	// End - is the location of the closing bracket, or of the last token,
+	// when the bracket is missing. Formatter needs it to keep comments and blank lines.
+	End Location
 }
 
 // Expression, that represents a variable assignment
 type AssignmentExpression struct {
-	Lhs Expression
-	Rhs Expression
+	Lhs      Expression
+	Rhs      Expression
+	Location Location
+}
+
+// Expression, that represents access to an attribute: `Object.Property`
+type MemberExpression struct {
+	Object   Expression
+	Property string
+	Location Location
+}
+
+// Expression, that represents several comma-separated assignment targets: `a,b = Foo[]`.
+// It is only parsed as `Lhs` of an assignment inside `Do`.
+type TupleExpression struct {
+	Elements []Expression
+	Location Location
+}
+
+// Expression, that represents a line comment, which precedes the next top-level expression.
+// It is produced only when lexer keeps comments, see `lexer.Lexer.KeepComments`.
+// Text is everything after `//`.
+type CommentExpression struct {
+	Text     string
+	Location Location
 }
 
 // Implementing interface
 func (VariableReferenceExpression) IsExpression() bool { return true }
 func (LiteralNumberExpression) IsExpression() bool     { return true }
+func (LiteralStringExpression) IsExpression() bool     { return true }
+func (LiteralCharExpression) IsExpression() bool       { return true }
 func (CallExpression) IsExpression() bool              { return true }
 func (AssignmentExpression) IsExpression() bool        { return true }
+func (MemberExpression) IsExpression() bool            { return true }
+func (TupleExpression) IsExpression() bool             { return true }
+func (CommentExpression) IsExpression() bool           { return true }
+
+// LocationOf - returns location of any expression,
+// and zero location for unknown expression types.
+func LocationOf(e Expression) Location {
+	switch e := e.(type) {
+	case VariableReferenceExpression:
+		return e.Location
+	case LiteralNumberExpression:
+		return e.Location
+	case LiteralStringExpression:
+		return e.Location
+	case LiteralCharExpression:
+		return e.Location
+	case CallExpression:
+		return e.Location
+	case AssignmentExpression:
+		return e.Location
+	case MemberExpression:
+		return e.Location
+	case TupleExpression:
+		return e.Location
+	case CommentExpression:
+		return e.Location
+	}
+
+	return Location{}
+}
 
 // Block statement, like in normal languages, carries a bunch of other statements or expressions
 type BlockStatement struct {
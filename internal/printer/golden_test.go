@@ -0,0 +1,128 @@
+package printer
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/fuale/eicg/internal/lexer"
+	"github.com/fuale/eicg/internal/parser"
+)
+
+// update - rewrites golden files from the current output instead of comparing,
+// review the diff before committing them:
+//
+//	go test ./internal/printer -run TestGolden -update
+var update = flag.Bool("update", false, "rewrite golden files from the current output")
+
+// backends - prints AST for every golden extension.
+var backends = map[string]func(p *Printer) (string, error){
+	".py":  func(p *Printer) (string, error) { return p.PrintPython() },
+	".js":  func(p *Printer) (string, error) { return p.PrintJavaScript() },
+	".ts":  func(p *Printer) (string, error) { return p.PrintTypeScript() },
+	".go":  func(p *Printer) (string, error) { return p.PrintGo() },
+	".scm": func(p *Printer) (string, error) { return p.PrintScheme() },
+	".sh":  func(p *Printer) (string, error) { return p.PrintBash() },
+}
+
+// TestGolden - compares output of backends with the expected one, committed next to the sources.
+//
+// Every `testdata/<name>.ei` is compiled by each backend, which has a golden file `<name>.<ext>`,
+// e.g. `<name>.py`, and the output must match it byte by byte.
+// Missing python golden is an error, other backends are checked only when there is a golden.
+func TestGolden(t *testing.T) {
+	sources, err := filepath.Glob(filepath.Join("testdata", "*.ei"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) == 0 {
+		t.Fatal("no sources in testdata")
+	}
+
+	for _, source := range sources {
+		for _, golden := range goldens(source) {
+			golden := golden
+			t.Run(filepath.Base(golden), func(t *testing.T) {
+				actual := compileGolden(t, source, filepath.Ext(golden))
+
+				if *update {
+					if err := os.WriteFile(golden, []byte(actual), 0644); err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+
+				expected, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatalf("%s, run with -update to create it", err)
+				}
+
+				if !bytes.Equal(expected, []byte(actual)) {
+					t.Errorf("output differs, run with -update if it is expected:\n--- expected\n%s\n--- actual\n%s", expected, actual)
+				}
+			})
+		}
+	}
+}
+
+// goldens - returns golden files of the source, in a stable order.
+// Python golden is always there, because it is the main backend.
+func goldens(source string) []string {
+	base := strings.TrimSuffix(source, ".ei")
+
+	files := []string{base + ".py"}
+	for ext := range backends {
+		if _, err := os.Stat(base + ext); ext != ".py" && err == nil {
+			files = append(files, base+ext)
+		}
+	}
+
+	sort.Strings(files[1:])
+	return files
+}
+
+// compileGolden - compiles the source with the backend of the golden extension.
+// Only python prints comments, so they are kept only for it, like `compiler.Options.KeepComments`.
+func compileGolden(t *testing.T, source, ext string) string {
+	t.Helper()
+
+	src, err := os.Open(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	lex := lexer.New(src)
+	lex.KeepComments = ext == ".py"
+
+	ast, err := parser.New(lex).Parse()
+	if err != nil {
+		t.Fatalf("parsing %s: %s", source, err)
+	}
+
+	out, err := backends[ext](New(ast))
+	if err != nil {
+		t.Fatalf("printing %s: %s", source, err)
+	}
+
+	return out
+}
+
+func TestGoldens(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.ei", "a.scm", "a.js", "a.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	base := filepath.Join(dir, "a")
+	want := []string{base + ".py", base + ".js", base + ".scm"}
+	if got := goldens(base + ".ei"); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
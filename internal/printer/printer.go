@@ -1,8 +1,15 @@
 package printer
 
 import (
+	"github.com/fuale/eicg/internal/lexer"
 	"github.com/fuale/eicg/internal/parser"
+	"github.com/fuale/eicg/internal/printer/printers/bash"
+	"github.com/fuale/eicg/internal/printer/printers/eicg"
+	"github.com/fuale/eicg/internal/printer/printers/golang"
+	"github.com/fuale/eicg/internal/printer/printers/javascript"
 	"github.com/fuale/eicg/internal/printer/printers/python"
+	"github.com/fuale/eicg/internal/printer/printers/scheme"
+	"github.com/fuale/eicg/internal/printer/printers/typescript"
 )
 
 type Printer struct {
@@ -15,7 +22,39 @@ func New(ast parser.Statement) *Printer {
 	}
 }
 
-func (p *Printer) PrintPython() string {
+func (p *Printer) PrintPython() (string, error) {
 	pp := python.Printer{}
 	return pp.String(p.Ast)
 }
+
+func (p *Printer) PrintScheme() (string, error) {
+	sp := scheme.Printer{}
+	return sp.String(p.Ast)
+}
+
+func (p *Printer) PrintGo() (string, error) {
+	gp := golang.Printer{}
+	return gp.String(p.Ast)
+}
+
+func (p *Printer) PrintJavaScript() (string, error) {
+	jp := javascript.Printer{}
+	return jp.String(p.Ast)
+}
+
+func (p *Printer) PrintTypeScript() (string, error) {
+	tp := typescript.Printer{}
+	return tp.String(p.Ast)
+}
+
+func (p *Printer) PrintBash() (string, error) {
+	bp := bash.Printer{}
+	return bp.String(p.Ast)
+}
+
+// PrintEicg - prints the AST back as eicg source in the canonical form.
+// Tokens of the source give comments inside calls, which are not a part of the AST, see `eicg.Printer`.
+func (p *Printer) PrintEicg(tokens []lexer.Token) string {
+	ep := eicg.Printer{Comments: tokens}
+	return ep.String(p.Ast)
+}
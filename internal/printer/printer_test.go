@@ -0,0 +1,39 @@
+package printer
+
+import (
+	"testing"
+
+	"github.com/fuale/eicg/internal/parser"
+)
+
+// TestMalformedAST - AST of shapes, which the parser doesn't produce, but a transformation pass might,
+// gives an error in every backend rather than a panic or an output.
+func TestMalformedAST(t *testing.T) {
+	number := parser.LiteralNumberExpression{Value: "1"}
+	malformed := map[string]parser.Expression{
+		"nil argument":          parser.CallExpression{Call: "Print", Args: []parser.Expression{nil}},
+		"assignment to number":  parser.CallExpression{Call: "Def", Args: []parser.Expression{parser.AssignmentExpression{Lhs: number, Rhs: number}}},
+		"member of nil":         parser.CallExpression{Call: "Print", Args: []parser.Expression{parser.MemberExpression{Property: "name"}}},
+		"call without a name":   parser.CallExpression{Args: []parser.Expression{number}},
+		"nil parameters":        parser.CallExpression{Call: "Def", Args: []parser.Expression{parser.VariableReferenceExpression{Value: "F"}, parser.CallExpression{Call: "Args", Args: []parser.Expression{nil}}, number}},
+		"top-level tuple":       parser.TupleExpression{Elements: []parser.Expression{number}},
+		"nil top-level":         nil,
+		"Let binding of number": parser.CallExpression{Call: "Let", Args: []parser.Expression{parser.AssignmentExpression{Lhs: number, Rhs: number}, number}},
+	}
+
+	for name, e := range malformed {
+		p := New(parser.BlockStatement{Expressions: []parser.Expression{e}})
+		for backend, print := range map[string]func() (string, error){
+			"python":     p.PrintPython,
+			"scheme":     p.PrintScheme,
+			"go":         p.PrintGo,
+			"javascript": p.PrintJavaScript,
+			"typescript": p.PrintTypeScript,
+			"bash":       p.PrintBash,
+		} {
+			if out, err := print(); err == nil {
+				t.Errorf("%s, %s: expected an error, got:\n%s", name, backend, out)
+			}
+		}
+	}
+}
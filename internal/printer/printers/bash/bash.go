@@ -0,0 +1,220 @@
+package bash
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fuale/eicg/internal/parser"
+)
+
+var ErrUnsupported = errors.New("unsupported in bash")
+
+// Printer - prints AST as a Bash script.
+//
+// Bash has no values besides strings, so only a small subset is supported:
+// `Print` becomes `echo`, `Let` becomes variable assignments (`local` inside
+// functions), `Concat` joins strings and `Def` defines a shell function,
+// which "returns" its body by echoing it. Calls to user functions are
+// commands, or command substitutions when used as a value.
+//
+// Anything else results in `ErrUnsupported`, because emitting a script
+// which silently does something different is worse than no script at all.
+type Printer struct {
+	// err - is the first error occurred while printing.
+	// Printing continues after the error, but the output is discarded.
+	err error
+
+	// location - is the location of the top-level expression being printed, reported with recovered panics.
+	location parser.Location
+}
+
+// String - prints the AST as a bash script.
+//
+// Unexpected AST, which isn't produced by the parser, may panic the printer.
+// The panic is returned as `parser.ErrInternal` with the location of the top-level expression.
+func (p *Printer) String(ast parser.Statement) (out string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			out, err = "", fmt.Errorf("%w: %v near %s", parser.ErrInternal, r, p.location.String())
+		}
+	}()
+
+	st := p.printStatement(ast)
+	if p.err != nil {
+		return "", p.err
+	}
+
+	return fmt.Sprintf("#!/usr/bin/env bash\n\n%s\n", st), nil
+}
+
+// unsupported - records the error and returns a placeholder
+// so callers don't have to check for errors on every step.
+func (p *Printer) unsupported(what string) string {
+	if p.err == nil {
+		p.err = fmt.Errorf("%w: %s", ErrUnsupported, what)
+	}
+
+	return "<unsupported>"
+}
+
+func (p *Printer) printStatement(s parser.Statement) string {
+	switch s := s.(type) {
+	case parser.BlockStatement:
+		lines := make([]string, 0)
+		for _, ee := range s.Expressions {
+			p.location = parser.LocationOf(ee)
+			lines = append(lines, p.printCommand(ee, false)...)
+		}
+		return strings.Join(lines, "\n")
+	default:
+		return p.unsupported("statement")
+	}
+}
+
+// printCommand - prints expression in a command position.
+// `local` tells whether we are inside a function body.
+func (p *Printer) printCommand(e parser.Expression, local bool) []string {
+	c, ok := e.(parser.CallExpression)
+	if !ok {
+		return []string{fmt.Sprintf("echo %s", p.printValue(e))}
+	}
+
+	if c.Callee != nil {
+		return []string{p.unsupported("method call")}
+	}
+	if c.Call == "" {
+		return []string{p.unsupported("call without a name")}
+	}
+
+	switch c.Call {
+	case "Print":
+		words := []string{"echo"}
+		for _, a := range c.Args {
+			words = append(words, p.printValue(a))
+		}
+		return []string{strings.Join(words, " ")}
+	case "Let":
+		if len(c.Args) == 0 {
+			return []string{p.unsupported("Let without body")}
+		}
+
+		lines := make([]string, 0)
+		for _, arg := range c.Args[:len(c.Args)-1] {
+			lines = append(lines, p.printBinding(arg, local))
+		}
+		return append(lines, p.printCommand(c.Args[len(c.Args)-1], local)...)
+	case "Def":
+		return p.printDef(c)
+	case "Concat":
+		return []string{fmt.Sprintf("echo %s", p.printValue(c))}
+	}
+
+	// Builtins besides the ones above have no sensible bash equivalent,
+	// and as commands they would only fail when the script runs.
+	if parser.IsBuiltin(c.Call) {
+		return []string{p.unsupported(c.Call)}
+	}
+
+	words := []string{c.Call}
+	for _, a := range c.Args {
+		words = append(words, p.printValue(a))
+	}
+	return []string{strings.Join(words, " ")}
+}
+
+// printBinding - prints `name = value` as a variable assignment.
+func (p *Printer) printBinding(e parser.Expression, local bool) string {
+	a, ok := e.(parser.AssignmentExpression)
+	if !ok {
+		return p.unsupported("Let binding without a value")
+	}
+
+	name, ok := a.Lhs.(parser.VariableReferenceExpression)
+	if !ok {
+		return p.unsupported("assignment to non-variable")
+	}
+
+	if local {
+		return fmt.Sprintf("local %s=%s", name.Value, p.printValue(a.Rhs))
+	}
+
+	return fmt.Sprintf("%s=%s", name.Value, p.printValue(a.Rhs))
+}
+
+// printDef - prints `Def` as a shell function, where parameters are positional.
+func (p *Printer) printDef(c parser.CallExpression) []string {
+	if len(c.Args) == 0 {
+		return []string{p.unsupported("Def without a name")}
+	}
+
+	if len(c.Args) == 1 {
+		return []string{p.printBinding(c.Args[0], false)}
+	}
+
+	defname, ok := c.Args[0].(parser.VariableReferenceExpression)
+	if !ok || len(c.Args) < 3 {
+		return []string{p.unsupported("Def shape")}
+	}
+
+	lines := []string{fmt.Sprintf("%s() {", defname.Value)}
+
+	if paramDef, ok := c.Args[1].(parser.CallExpression); ok && paramDef.Call == "Args" {
+		for i, arg := range paramDef.Args {
+			param, ok := arg.(parser.VariableReferenceExpression)
+			if !ok {
+				return []string{p.unsupported("Def parameter other than a plain name")}
+			}
+			lines = append(lines, fmt.Sprintf("  local %s=\"$%d\"", param.Value, i+1))
+		}
+	}
+
+	for _, line := range p.printCommand(c.Args[2], true) {
+		lines = append(lines, "  "+line)
+	}
+
+	return append(lines, "}")
+}
+
+// printValue - prints expression in a value position as a quoted word.
+func (p *Printer) printValue(e parser.Expression) string {
+	return fmt.Sprintf("\"%s\"", p.printWord(e))
+}
+
+// printWord - prints the contents of a double-quoted word.
+func (p *Printer) printWord(e parser.Expression) string {
+	switch e := e.(type) {
+	case parser.LiteralNumberExpression:
+		return NormalizeNumber(e.Value)
+	case parser.LiteralStringExpression:
+		// Inside double quotes only these runes are special to bash.
+		return strings.NewReplacer("$", "\\$", "`", "\\`").Replace(e.Value)
+	case parser.LiteralCharExpression:
+		// Unlike strings, char may be a double quote itself
+		return strings.NewReplacer("$", "\\$", "`", "\\`", "\"", "\\\"").Replace(e.Value)
+	case parser.VariableReferenceExpression:
+		return fmt.Sprintf("${%s}", e.Value)
+	case parser.CallExpression:
+		if e.Call == "Concat" {
+			parts := make([]string, 0)
+			for _, a := range e.Args {
+				parts = append(parts, p.printWord(a))
+			}
+			return strings.Join(parts, "")
+		}
+
+		if parser.IsBuiltin(e.Call) {
+			return p.unsupported(fmt.Sprintf("%s as a value", e.Call))
+		}
+
+		return fmt.Sprintf("$(%s)", strings.Join(p.printCommand(e, false), "; "))
+	}
+
+	return p.unsupported(fmt.Sprintf("%T", e))
+}
+
+// NormalizeNumber - converts the lexed number to a bash word.
+// Bash arithmetic has no digit separators, so `_` are dropped: `1_000` is `1000`.
+func NormalizeNumber(value string) string {
+	return strings.ReplaceAll(value, "_", "")
+}
@@ -0,0 +1,129 @@
+package bash
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/fuale/eicg/internal/lexer"
+	"github.com/fuale/eicg/internal/parser"
+)
+
+// compile - prints the source as bash, returning the printer error.
+func compile(t *testing.T, src string) (string, error) {
+	t.Helper()
+
+	ast, err := parser.New(lexer.New(strings.NewReader(src))).Parse()
+	if err != nil {
+		t.Fatalf("parsing %q: %s", src, err)
+	}
+
+	p := Printer{}
+	return p.String(ast)
+}
+
+const program = `
+Def[Greet, Args[name], Concat["hello, ", name]]
+Let[who = "world", Print[Greet[who]]]
+Def[Total = 42]
+Print["total:", Total, 'x']
+Print["$HOME is not expanded"]
+`
+
+func TestSupportedSubset(t *testing.T) {
+	want := `#!/usr/bin/env bash
+
+Greet() {
+  local name="$1"
+  echo "hello, ${name}"
+}
+who="world"
+echo "$(Greet "${who}")"
+Total="42"
+echo "total:" "${Total}" "x"
+echo "\$HOME is not expanded"
+`
+
+	got, err := compile(t, program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestRun - runs the script, when bash is available.
+func TestRun(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not available")
+	}
+
+	script, err := compile(t, program)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(bash, "-s")
+	cmd.Stdin = strings.NewReader(script)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "hello, world\ntotal: 42 x\n$HOME is not expanded\n"; string(out) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	for _, src := range []string{
+		"Print[List[1, 2]]",
+		"Map[F, List[1]]",
+		"Print[Let[x = 1, x]]",
+		"Def[F, Args[x = 1], x]",
+		"Def[]",
+		"Def[F, Args[x]]",
+		"Let[]",
+		"xs.append[1]",
+		"Print[obj.name]",
+		"Def[F, Args[a], Do[a, a]]",
+	} {
+		if _, err := compile(t, src); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%q: expected ErrUnsupported, got %v", src, err)
+		}
+	}
+}
+
+// TestBuiltins - builtins of other backends are errors, rather than commands which don't exist.
+func TestBuiltins(t *testing.T) {
+	_, err := compile(t, "Sort[xs]")
+	if !errors.Is(err, ErrUnsupported) || err.Error() != "unsupported in bash: Sort" {
+		t.Errorf("got %v", err)
+	}
+
+	for name := range parser.Builtins {
+		if name == "Print" || name == "Let" || name == "Def" {
+			continue
+		}
+
+		for _, src := range []string{name + "[x, x]", "Print[" + name + "[x, x]]"} {
+			if _, err := compile(t, src); !errors.Is(err, ErrUnsupported) {
+				t.Errorf("%q: expected ErrUnsupported, got %v", src, err)
+			}
+		}
+	}
+}
+
+// TestNumbers - bash has no digit separators, so they are dropped.
+func TestNumbers(t *testing.T) {
+	got, err := compile(t, "Def[N = 1_000]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, `N="1000"`) {
+		t.Errorf("got:\n%s", got)
+	}
+}
@@ -0,0 +1,294 @@
+package eicg
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fuale/eicg/internal/lexer"
+	"github.com/fuale/eicg/internal/parser"
+)
+
+// LineWidth - is the width a call must fit into to be printed on one line.
+const LineWidth = 80
+
+// tabWidth - is how many columns a tab takes when measuring lines.
+const tabWidth = 4
+
+// Printer - prints AST back as eicg source in the canonical form,
+// like `gofmt` does for go. Output parsed again gives the same AST,
+// and printing it once more gives the same output.
+//
+// Calls are printed on one line when they fit into `LineWidth`,
+// otherwise every argument goes on its own line one tab deeper,
+// and the closing bracket goes on a line of its own:
+//
+//	Def[
+//		CacheResult,
+//		Args[f, HashMap[kv]],
+//		Let[x, Cond[Has[x, kv], Get[x, kv], Assoc[x, f[x], kv]]]
+//	]
+//
+// `Eq[a, b]` is printed as `a == b` where the parser accepts comparisons, i.e. in arguments.
+// A single blank line between top-level expressions is kept, more are collapsed.
+//
+// Top-level comments are a part of the AST, when it is parsed with `lexer.Lexer.KeepComments`.
+// Comments inside calls are not, they are printed only when given in `Comments`.
+type Printer struct {
+	// Comments - are tokens of the source, e.g. given by `parser.Parser.ParseTokens`,
+	// only comments among them are used. A comment inside a call is printed on its own line
+	// before the argument it precedes in the source, or before the closing bracket,
+	// so the call is never printed on one line.
+	Comments []lexer.Token
+
+	// comments - are comments from `Comments`, which are inside calls.
+	comments []lexer.Token
+
+	// next - is the index of the next comment to print.
+	next int
+}
+
+func (p *Printer) String(ast parser.Statement) string {
+	block, ok := ast.(parser.BlockStatement)
+	if !ok {
+		return ""
+	}
+
+	// Top-level comments are printed as expressions, so they are skipped here
+	topLevel := make(map[parser.Location]bool)
+	for _, e := range block.Expressions {
+		if c, ok := e.(parser.CommentExpression); ok {
+			topLevel[c.Location] = true
+		}
+	}
+
+	p.comments, p.next = make([]lexer.Token, 0), 0
+	for _, token := range p.Comments {
+		if token.Typ == lexer.TokenComment && !topLevel[token.Location] {
+			p.comments = append(p.comments, token)
+		}
+	}
+
+	lines := make([]string, 0, len(block.Expressions))
+	for i, e := range block.Expressions {
+		if i > 0 && parser.LocationOf(e).Row > endRow(block.Expressions[i-1])+1 {
+			lines = append(lines, "")
+		}
+
+		lines = append(lines, p.commentsBefore(parser.LocationOf(e))...)
+		lines = append(lines, p.printObject(e, 0))
+	}
+
+	// Comments of a call, which is not closed at the end of file
+	lines = append(lines, p.commentsBefore(parser.Location{Row: math.MaxInt})...)
+
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// printExpression - prints the expression at the nesting level `indent`.
+// The first line is not indented, because it continues the line of the parent.
+func (p *Printer) printExpression(e parser.Expression, indent int) string {
+	if c, ok := e.(parser.CallExpression); ok && !infix(c) {
+		return p.printCall(c, indent)
+	}
+
+	flat := p.printFlat(e)
+	if p.fits(flat, indent) && !p.hasComments(e) {
+		return flat
+	}
+
+	switch e := e.(type) {
+	case parser.CallExpression:
+		// Comparison, which doesn't fit, is broken as a call
+		return p.printCall(e, indent)
+	case parser.AssignmentExpression:
+		return fmt.Sprintf("%s = %s", p.printFlat(e.Lhs), p.printExpression(e.Rhs, indent))
+	case parser.MemberExpression:
+		return fmt.Sprintf("%s.%s", p.printObject(e.Object, indent), e.Property)
+	}
+
+	// Names and literals can't be broken
+	return flat
+}
+
+// printObject - is `printExpression` for places, where comparisons are not parsed:
+// top-level expressions, objects of members and right operands of `==`.
+func (p *Printer) printObject(e parser.Expression, indent int) string {
+	if c, ok := e.(parser.CallExpression); ok {
+		return p.printCall(c, indent)
+	}
+
+	return p.printExpression(e, indent)
+}
+
+// printCall - prints the call in brackets, even if it is a comparison.
+func (p *Printer) printCall(e parser.CallExpression, indent int) string {
+	flat := p.printFlatCall(e)
+	if p.fits(flat, indent) && !p.hasComments(e) {
+		return flat
+	}
+
+	callee := e.Call
+	if e.Callee != nil {
+		callee = p.printObject(e.Callee, indent)
+	}
+
+	inner := strings.Repeat("\t", indent+1)
+	lines := make([]string, 0, len(e.Args)+2)
+	lines = append(lines, callee+"[")
+	for i, a := range e.Args {
+		for _, c := range p.commentsBefore(parser.LocationOf(a)) {
+			lines = append(lines, inner+c)
+		}
+
+		arg := inner + p.printExpression(a, indent+1)
+		if i < len(e.Args)-1 {
+			arg += ","
+		}
+		lines = append(lines, arg)
+	}
+	for _, c := range p.commentsBefore(e.End) {
+		lines = append(lines, inner+c)
+	}
+	lines = append(lines, strings.Repeat("\t", indent)+"]")
+
+	return strings.Join(lines, "\n")
+}
+
+// printFlat - prints the expression on a single line.
+func (p *Printer) printFlat(e parser.Expression) string {
+	switch e := e.(type) {
+	case parser.CallExpression:
+		if infix(e) {
+			return fmt.Sprintf("%s == %s", p.printFlat(e.Args[0]), p.printFlatObject(e.Args[1]))
+		}
+		return p.printFlatCall(e)
+	case parser.AssignmentExpression:
+		return fmt.Sprintf("%s = %s", p.printFlat(e.Lhs), p.printFlat(e.Rhs))
+	case parser.TupleExpression:
+		elements := make([]string, 0, len(e.Elements))
+		for _, element := range e.Elements {
+			elements = append(elements, p.printFlat(element))
+		}
+		// Targets are joined without spaces, otherwise they are separate statements
+		return strings.Join(elements, ",")
+	case parser.MemberExpression:
+		return fmt.Sprintf("%s.%s", p.printFlatObject(e.Object), e.Property)
+	case parser.VariableReferenceExpression:
+		return e.Value
+	case parser.LiteralNumberExpression:
+		return e.Value
+	case parser.LiteralStringExpression:
+		// Value is kept with escape sequences, so it is printed as is
+		return fmt.Sprintf("\"%s\"", e.Value)
+	case parser.LiteralCharExpression:
+		return fmt.Sprintf("'%s'", e.Value)
+	case parser.CommentExpression:
+		return "//" + e.Text
+	}
+
+	return "<unknown>"
+}
+
+// printFlatObject - is `printObject` on a single line.
+func (p *Printer) printFlatObject(e parser.Expression) string {
+	if c, ok := e.(parser.CallExpression); ok {
+		return p.printFlatCall(c)
+	}
+
+	return p.printFlat(e)
+}
+
+func (p *Printer) printFlatCall(e parser.CallExpression) string {
+	callee := e.Call
+	if e.Callee != nil {
+		callee = p.printFlatObject(e.Callee)
+	}
+
+	args := make([]string, 0, len(e.Args))
+	for _, a := range e.Args {
+		args = append(args, p.printFlat(a))
+	}
+
+	return fmt.Sprintf("%s[%s]", callee, strings.Join(args, ", "))
+}
+
+// fits - tells whether the line fits into `LineWidth` at the nesting level `indent`.
+func (p *Printer) fits(line string, indent int) bool {
+	return indent*tabWidth+utf8.RuneCountInString(line) <= LineWidth
+}
+
+// hasComments - tells whether there are comments inside the expression.
+// Comments before it are already printed, so only the end is checked.
+func (p *Printer) hasComments(e parser.Expression) bool {
+	return p.next < len(p.comments) && before(p.comments[p.next].Location, end(e))
+}
+
+// commentsBefore - returns comments, which are not printed yet and precede the location.
+func (p *Printer) commentsBefore(location parser.Location) []string {
+	lines := make([]string, 0)
+	for p.next < len(p.comments) && before(p.comments[p.next].Location, location) {
+		lines = append(lines, "//"+p.comments[p.next].Value)
+		p.next++
+	}
+
+	return lines
+}
+
+// infix - tells whether the call is printed as a comparison: `a == b`.
+// Assignments can't be operands, and the right operand is parsed before comparisons,
+// so `Eq[a, x = 1]` and `Eq[a, Eq[b, c]]` keep brackets, the latter only the inner ones.
+func infix(e parser.CallExpression) bool {
+	if e.Call != "Eq" || e.Callee != nil || len(e.Args) != 2 {
+		return false
+	}
+
+	for _, operand := range e.Args {
+		switch operand.(type) {
+		case parser.AssignmentExpression, parser.TupleExpression, parser.CommentExpression:
+			return false
+		}
+	}
+
+	return true
+}
+
+// end - returns location of the end of the expression, as far as it is known:
+// the closing bracket of a call, or the start of anything else.
+func end(e parser.Expression) parser.Location {
+	switch e := e.(type) {
+	case parser.CallExpression:
+		if e.End.Row != 0 {
+			return e.End
+		}
+	case parser.AssignmentExpression:
+		return end(e.Rhs)
+	case parser.MemberExpression:
+		return end(e.Object)
+	case parser.TupleExpression:
+		if len(e.Elements) > 0 {
+			return end(e.Elements[len(e.Elements)-1])
+		}
+	}
+
+	return parser.LocationOf(e)
+}
+
+// endRow - is the last row of the expression, for counting blank lines after it.
+func endRow(e parser.Expression) int {
+	if location := end(e); location.Row > parser.LocationOf(e).Row {
+		return location.Row
+	}
+
+	return parser.LocationOf(e).Row
+}
+
+// before - tells whether the location `a` precedes `b` in the source.
+func before(a, b parser.Location) bool {
+	return a.Row < b.Row || (a.Row == b.Row && a.Col < b.Col)
+}
@@ -0,0 +1,140 @@
+package eicg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fuale/eicg/internal/lexer"
+	"github.com/fuale/eicg/internal/parser"
+)
+
+// parse - parses the source keeping comments, and returns its tokens too.
+func parse(t *testing.T, src string) (parser.Statement, []lexer.Token) {
+	t.Helper()
+
+	l := lexer.New(strings.NewReader(src))
+	l.KeepComments = true
+	ast, tokens, err := parser.New(l).ParseTokens()
+	if err != nil {
+		t.Fatalf("parsing %q: %s", src, err)
+	}
+
+	return ast, tokens
+}
+
+// format - prints the source in the canonical form.
+func format(t *testing.T, src string) string {
+	t.Helper()
+
+	ast, tokens := parse(t, src)
+	p := Printer{Comments: tokens}
+	return p.String(ast)
+}
+
+// expectRoundTrip - formats the source and checks, that the output is the expected one,
+// it parses to the same AST, and formatting it again changes nothing.
+func expectRoundTrip(t *testing.T, src, want string) {
+	t.Helper()
+
+	got := format(t, src)
+	if got != want {
+		t.Errorf("%q:\ngot:\n%s\nwant:\n%s", src, got, want)
+		return
+	}
+
+	before, _ := parse(t, src)
+	after, _ := parse(t, got)
+	if !parser.EqualStatements(before, after) {
+		t.Errorf("%q: formatted source parses to another AST:\n%s", src, got)
+	}
+
+	if again := format(t, got); again != got {
+		t.Errorf("%q: formatting is not idempotent:\n%s\nthen:\n%s", src, got, again)
+	}
+}
+
+func TestFlat(t *testing.T) {
+	expectRoundTrip(t, "Print[ 1,2 ,\"s\", 'c' ]", "Print[1, 2, \"s\", 'c']\n")
+	expectRoundTrip(t, "Do[a,b = F[], xs.append[a].pop[], obj.name]", "Do[a,b = F[], xs.append[a].pop[], obj.name]\n")
+	expectRoundTrip(t, "Do[x,a = 1, y, b = 2]", "Do[x,a = 1, y, b = 2]\n")
+	expectRoundTrip(t, "Print[1];Print[2]", "Print[1]\nPrint[2]\n")
+	expectRoundTrip(t, "", "")
+}
+
+func TestBroken(t *testing.T) {
+	src := "Def[VeryLongFunctionName, Args[first, second, third], Print[first, second, third, \"and more text\"]]"
+	want := `Def[
+	VeryLongFunctionName,
+	Args[first, second, third],
+	Print[first, second, third, "and more text"]
+]
+`
+	expectRoundTrip(t, src, want)
+}
+
+func TestEq(t *testing.T) {
+	expectRoundTrip(t, "Print[Eq[a, 1]]", "Print[a == 1]\n")
+	expectRoundTrip(t, "Print[a == b == c]", "Print[a == b == c]\n")
+	expectRoundTrip(t, "Do[x = Eq[a, 1]]", "Do[x = a == 1]\n")
+
+	// Only where comparisons are parsed, and only when operands allow it
+	expectRoundTrip(t, "Eq[a, 1]", "Eq[a, 1]\n")
+	expectRoundTrip(t, "Print[Eq[a, Eq[b, c]]]", "Print[a == Eq[b, c]]\n")
+	expectRoundTrip(t, "Print[Eq[a, b].x]", "Print[Eq[a, b].x]\n")
+	expectRoundTrip(t, "Print[Eq[a, x = 1]]", "Print[Eq[a, x = 1]]\n")
+	expectRoundTrip(t, "Print[Eq[a]]", "Print[Eq[a]]\n")
+}
+
+func TestBlankLines(t *testing.T) {
+	expectRoundTrip(t, "Print[1]\n\nPrint[2]\nPrint[3]", "Print[1]\n\nPrint[2]\nPrint[3]\n")
+
+	// Several blank lines are collapsed, and lines inside calls are not blank lines between them
+	expectRoundTrip(t, "Print[1,\n\n\n2]\n\n\n\nPrint[3]", "Print[1, 2]\n\nPrint[3]\n")
+	expectRoundTrip(t, "// first\n\n// second\nPrint[1]", "// first\n\n// second\nPrint[1]\n")
+}
+
+func TestComments(t *testing.T) {
+	src := `// header
+Def[Square, Args[x], // the argument
+  Mul[x, x]]
+Print[Cond[a, 1, // last
+2], F[ // only comment
+]] // trailing
+`
+	want := `// header
+Def[
+	Square,
+	Args[x],
+	// the argument
+	Mul[x, x]
+]
+Print[
+	Cond[
+		a,
+		1,
+		// last
+		2
+	],
+	F[
+		// only comment
+	]
+]
+// trailing
+`
+	expectRoundTrip(t, src, want)
+
+	// Comment before the closing bracket
+	expectRoundTrip(t, "Print[1, 2 // end\n]", "Print[\n\t1,\n\t2\n\t// end\n]\n")
+
+	// Comment in a deeply nested call breaks only the calls around it
+	expectRoundTrip(t, "Print[List[1], F[G[1, // c\n2]]]", "Print[\n\tList[1],\n\tF[\n\t\tG[\n\t\t\t1,\n\t\t\t// c\n\t\t\t2\n\t\t]\n\t]\n]\n")
+}
+
+// TestNoComments - without tokens nested comments are lost, but the rest is the same.
+func TestNoComments(t *testing.T) {
+	ast, _ := parse(t, "// top\nPrint[1, // nested\n2]")
+	p := Printer{}
+	if got := p.String(ast); got != "// top\nPrint[1, 2]\n" {
+		t.Errorf("got %q", got)
+	}
+}
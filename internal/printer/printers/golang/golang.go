@@ -0,0 +1,322 @@
+package golang
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fuale/eicg/internal/parser"
+)
+
+var ErrInvalidCall = errors.New("invalid call")
+
+// Printer - prints AST as a Go program.
+//
+// Go is statically typed while eicg is not, so this printer makes
+// a few simplifying assumptions:
+//
+//   - The output is always a `package main`. Top-level `Def`s with
+//     parameters become functions, `Def[name = value]` becomes a
+//     package-level `var`, and everything else goes into `main`.
+//   - Every parameter and every return value is `interface{}`.
+//     Function values are `interface{}` too, so calling something
+//     that isn't a top-level `Def` may need a manual type assertion.
+//   - Arithmetic (`Add`, `Sub`, `Mul`, `Div`, `Inc`) is emitted as plain
+//     operators, which compiles only when operands are typed numbers.
+//   - `Cond` expects its test to be a `bool`, e.g. an `Eq`.
+type Printer struct {
+	usingFmt bool
+
+	// err - is the first error occurred while printing.
+	// Printing continues after the error, but the output is discarded.
+	err error
+
+	// location - is the location of the top-level expression being printed, reported with recovered panics.
+	location parser.Location
+}
+
+// String - prints the AST as a go program.
+//
+// Unexpected AST, which isn't produced by the parser, may panic the printer.
+// The panic is returned as `parser.ErrInternal` with the location of the top-level expression.
+func (p *Printer) String(ast parser.Statement) (out string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			out, err = "", fmt.Errorf("%w: %v near %s", parser.ErrInternal, r, p.location.String())
+		}
+	}()
+
+	decls, stmts := p.printStatement(ast)
+	if p.err != nil {
+		return "", p.err
+	}
+
+	out = "package main\n\n"
+	if p.usingFmt {
+		out += "import \"fmt\"\n\n"
+	}
+	for _, d := range decls {
+		out += d + "\n\n"
+	}
+	out += fmt.Sprintf("func main() {\n%s}\n", stmts)
+
+	return out, nil
+}
+
+// errorf - records the first error and returns a placeholder
+// so callers don't have to check for errors on every step.
+func (p *Printer) errorf(format string, a ...any) string {
+	if p.err == nil {
+		p.err = fmt.Errorf("%w: %s", ErrInvalidCall, fmt.Sprintf(format, a...))
+	}
+
+	return "<error>"
+}
+
+// printStatement - splits top-level expressions into package-level
+// declarations and statements of the `main` function.
+func (p *Printer) printStatement(s parser.Statement) (decls []string, stmts string) {
+	decls = make([]string, 0)
+
+	switch s := s.(type) {
+	case parser.BlockStatement:
+		for _, ee := range s.Expressions {
+			p.location = parser.LocationOf(ee)
+			if c, ok := ee.(parser.CallExpression); ok && c.Call == "Def" {
+				decls = append(decls, p.printExpression(c))
+				continue
+			}
+
+			stmts += fmt.Sprintf("\t%s\n", p.printTopLevel(ee))
+		}
+	default:
+		p.errorf("unsupported statement")
+	}
+
+	return decls, stmts
+}
+
+// printTopLevel - Go doesn't allow unused values as statements,
+// so anything besides a call is assigned to the blank identifier.
+func (p *Printer) printTopLevel(e parser.Expression) string {
+	if c, ok := e.(parser.CallExpression); ok {
+		if c.Call == "Print" {
+			p.usingFmt = true
+			return fmt.Sprintf("fmt.Println(%s)", strings.Join(p.printArgs(c.Args), ", "))
+		}
+
+		if _, isOperator := operators[c.Call]; !isOperator && c.Call != "Inc" {
+			return p.printExpression(c)
+		}
+	}
+
+	return fmt.Sprintf("_ = %s", p.printExpression(e))
+}
+
+// operators - builtins which maps directly to Go binary operators.
+var operators = map[string]string{
+	"Add": "+",
+	"Sub": "-",
+	"Mul": "*",
+	"Div": "/",
+}
+
+func (p *Printer) printArgs(exprs []parser.Expression) []string {
+	args := make([]string, 0)
+	for _, a := range exprs {
+		args = append(args, p.printExpression(a))
+	}
+	return args
+}
+
+func (p *Printer) printExpression(e parser.Expression) string {
+	switch e := e.(type) {
+	case parser.CallExpression:
+		// Definitions print their arguments on their own,
+		// so we handle them before printing arguments as expressions.
+		if e.Call == "Def" {
+			return p.printDef(e)
+		}
+
+		if e.Call == "Let" {
+			// The last argument is the body, so there must be at least one
+			if len(e.Args) == 0 {
+				return p.errorf("Let requires at least one argument: the body")
+			}
+
+			params, bindings := p.printParams(e.Args[:len(e.Args)-1])
+			return fmt.Sprintf("func(%s) interface{} { %sreturn %s }", params, bindings, p.printExpression(e.Args[len(e.Args)-1]))
+		}
+
+		args := p.printArgs(e.Args)
+
+		if e.Call == "Print" {
+			// Print is used as a value here, so we wrap it into
+			// a function which returns the first argument, like python backend does.
+			// `Print[]` prints a blank line and returns nil.
+			p.usingFmt = true
+
+			value := "nil"
+			if len(args) > 0 {
+				value = args[0]
+			}
+
+			return fmt.Sprintf("func() interface{} { fmt.Println(%s); return %s }()", strings.Join(args, ", "), value)
+		}
+
+		if op, ok := operators[e.Call]; ok {
+			return fmt.Sprintf("(%s)", strings.Join(args, fmt.Sprintf(" %s ", op)))
+		}
+
+		if e.Call == "List" {
+			return fmt.Sprintf("[]interface{}{%s}", strings.Join(args, ", "))
+		}
+
+		// HashMap[k1, v1, k2, v2] - is a map literal of key and value pairs
+		if e.Call == "HashMap" {
+			if len(args)%2 != 0 {
+				return p.errorf("HashMap requires pairs of key and value")
+			}
+
+			pairs := make([]string, 0, len(args)/2)
+			for i := 0; i < len(args); i += 2 {
+				pairs = append(pairs, fmt.Sprintf("%s: %s", args[i], args[i+1]))
+			}
+
+			return fmt.Sprintf("map[interface{}]interface{}{%s}", strings.Join(pairs, ", "))
+		}
+
+		if e.Call == "Call" {
+			if len(args) == 0 {
+				return p.errorf("Call requires at least one argument: the function")
+			}
+			return fmt.Sprintf("%s(%s)", args[0], strings.Join(args[1:], ", "))
+		}
+
+		if e.Call == "Eq" {
+			if len(args) != 2 {
+				return p.errorf("Eq accepts exactly two arguments")
+			}
+			return fmt.Sprintf("(%s == %s)", args[0], args[1])
+		}
+
+		if e.Call == "Cond" {
+			// Cond[t1, e1, t2, e2, ..., default] - pairs of test and result, then default
+			if len(args) < 3 || len(args)%2 == 0 {
+				return p.errorf("Cond requires an odd number of arguments, at least three")
+			}
+
+			branches := make([]string, 0, len(args)/2)
+			for i := 0; i < len(args)-1; i += 2 {
+				branches = append(branches, fmt.Sprintf("if %s { return %s }; ", args[i], args[i+1]))
+			}
+
+			return fmt.Sprintf("func() interface{} { %sreturn %s }()", strings.Join(branches, ""), args[len(args)-1])
+		}
+
+		if e.Call == "Inc" {
+			for i := range args {
+				args[i] = fmt.Sprintf("(%s + 1)", args[i])
+			}
+			return strings.Join(args, ", ")
+		}
+
+		callee := e.Call
+		if e.Callee != nil {
+			callee = p.printExpression(e.Callee)
+		} else if e.Call == "" {
+			return p.errorf("call has neither a name nor a callee at %s", e.Location)
+		}
+
+		return fmt.Sprintf("%s(%s)", callee, strings.Join(args, ", "))
+	case parser.LiteralNumberExpression:
+		return NormalizeNumber(e.Value)
+	case parser.LiteralStringExpression:
+		return fmt.Sprintf("\"%s\"", e.Value)
+	case parser.LiteralCharExpression:
+		return fmt.Sprintf("'%s'", e.Value)
+	case parser.VariableReferenceExpression:
+		return e.Value
+	case parser.MemberExpression:
+		return fmt.Sprintf("%s.%s", p.printExpression(e.Object), e.Property)
+	}
+
+	return p.errorf("unsupported expression at %s", parser.LocationOf(e))
+}
+
+// printDef - prints `Def[name, Args[...], body]` as a function,
+// and `Def[name = value]` as a variable.
+func (p *Printer) printDef(e parser.CallExpression) string {
+	if len(e.Args) == 0 {
+		return p.errorf("Def requires a name at %s", e.Location)
+	}
+
+	if defname, ok := e.Args[0].(parser.VariableReferenceExpression); ok {
+		if len(e.Args) < 3 {
+			return p.errorf("Def of %s requires parameters and a body at %s", defname.Value, e.Location)
+		}
+
+		params, bindings := "", ""
+		if paramDef, ok := e.Args[1].(parser.CallExpression); ok && paramDef.Call == "Args" {
+			params, bindings = p.printParams(paramDef.Args)
+		}
+
+		return fmt.Sprintf("func %s(%s) interface{} {\n\t%sreturn %s\n}", defname.Value, params, bindings, p.printExpression(e.Args[2]))
+	}
+
+	if a, ok := e.Args[0].(parser.AssignmentExpression); ok {
+		name, ok := a.Lhs.(parser.VariableReferenceExpression)
+		if !ok {
+			return p.errorf("Def can assign only to a name at %s", a.Location)
+		}
+		return fmt.Sprintf("var %s = %s", name.Value, p.printExpression(a.Rhs))
+	}
+
+	return p.errorf("Def requires a name at %s", e.Location)
+}
+
+// printParams - prints parameter list and variable declarations for
+// parameters with a value, because Go has no default parameter values.
+func (p *Printer) printParams(args []parser.Expression) (params string, bindings string) {
+	names := make([]string, 0)
+
+	for _, arg := range args {
+		if argname, ok := arg.(parser.VariableReferenceExpression); ok {
+			names = append(names, argname.Value)
+		} else if subargs, ok := arg.(parser.CallExpression); ok && subargs.Call == "Args" {
+			subparams, subbindings := p.printParams(subargs.Args)
+			if subparams != "" {
+				names = append(names, strings.TrimSuffix(subparams, " interface{}"))
+			}
+			bindings += subbindings
+		} else if subargs, ok := arg.(parser.CallExpression); ok && subargs.Call == "HashMap" {
+			if len(subargs.Args) != 1 {
+				bindings += p.errorf("HashMap currently accept only one argument")
+			} else if name, ok := subargs.Args[0].(parser.VariableReferenceExpression); ok {
+				bindings += fmt.Sprintf("var %s interface{} = map[interface{}]interface{}{}; ", name.Value)
+			} else {
+				bindings += p.errorf("HashMap accepts only a parameter name at %s", subargs.Location)
+			}
+		} else if a, ok := arg.(parser.AssignmentExpression); ok {
+			if name, ok := a.Lhs.(parser.VariableReferenceExpression); ok {
+				bindings += fmt.Sprintf("var %s interface{} = %s; ", name.Value, p.printExpression(a.Rhs))
+			} else {
+				bindings += p.errorf("default value must be assigned to a parameter name at %s", a.Location)
+			}
+		} else {
+			names = append(names, p.errorf("parameter must be a name at %s", parser.LocationOf(arg)))
+		}
+	}
+
+	if len(names) > 0 {
+		params = strings.Join(names, ", ") + " interface{}"
+	}
+
+	return params, bindings
+}
+
+// NormalizeNumber - converts the lexed number to go's syntax.
+// Go accepts every form the lexer does, including `_` separators, so it is kept as is.
+func NormalizeNumber(value string) string {
+	return value
+}
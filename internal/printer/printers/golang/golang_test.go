@@ -0,0 +1,150 @@
+package golang
+
+import (
+	"errors"
+	goparser "go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/fuale/eicg/internal/lexer"
+	"github.com/fuale/eicg/internal/parser"
+)
+
+// compile - prints the source as go, returning the printer error.
+func compile(t *testing.T, src string) (string, error) {
+	t.Helper()
+
+	ast, err := parser.New(lexer.New(strings.NewReader(src))).Parse()
+	if err != nil {
+		t.Fatalf("parsing %q: %s", src, err)
+	}
+
+	p := Printer{}
+	return p.String(ast)
+}
+
+func TestBasicMappings(t *testing.T) {
+	src := `
+Def[Square, Args[x], Mul[x, x]]
+Def[Limit = 10]
+Print[List[1, 2, 3]]
+Add[1, Sub[Limit, 2]]
+Print[Cond[Eq[Limit, 1], "one", Eq[Limit, 2], "two", "many"]]
+`
+	want := `package main
+
+import "fmt"
+
+func Square(x interface{}) interface{} {
+	return (x * x)
+}
+
+var Limit = 10
+
+func main() {
+	fmt.Println([]interface{}{1, 2, 3})
+	_ = (1 + (Limit - 2))
+	fmt.Println(func() interface{} { if (Limit == 1) { return "one" }; if (Limit == 2) { return "two" }; return "many" }())
+}
+`
+
+	got, err := compile(t, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Output is not gofmt-ed, but it must be valid go
+	if _, err := goparser.ParseFile(token.NewFileSet(), "main.go", got, 0); err != nil {
+		t.Errorf("output is not valid go: %s", err)
+	}
+}
+
+func TestNoFmtImportWithoutPrint(t *testing.T) {
+	got, err := compile(t, "Def[Limit = 10]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(got, "import") {
+		t.Errorf("unexpected import in:\n%s", got)
+	}
+}
+
+// TestInvalid - covers programs which used to panic.
+func TestInvalid(t *testing.T) {
+	for _, src := range []string{
+		"Print[Let[]]",
+		"Def[]",
+		"Def[F]",
+		"Def[1]",
+		"Def[F, Args[HashMap[]], 1]",
+		"Def[F, Args[1], 1]",
+		"Print[Eq[1]]",
+		"Print[Call[]]",
+		"Print[Cond[1, 2]]",
+		"Print[HashMap[1]]",
+	} {
+		if _, err := compile(t, src); !errors.Is(err, ErrInvalidCall) {
+			t.Errorf("%q: expected ErrInvalidCall, got %v", src, err)
+		}
+	}
+}
+
+func TestPrintWithoutArguments(t *testing.T) {
+	got, err := compile(t, "Def[X = Print[]]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "var X = func() interface{} { fmt.Println(); return nil }()"; !strings.Contains(got, want) {
+		t.Errorf("got:\n%s\nwant it to contain %s", got, want)
+	}
+}
+
+// TestInvalidAST - covers shapes, which the parser doesn't produce, but a transformation pass might.
+func TestInvalidAST(t *testing.T) {
+	ast := parser.BlockStatement{Expressions: []parser.Expression{
+		parser.CallExpression{Call: "Def", Args: []parser.Expression{
+			parser.AssignmentExpression{Lhs: parser.LiteralNumberExpression{Value: "1"}, Rhs: parser.LiteralNumberExpression{Value: "2"}},
+		}},
+	}}
+
+	p := Printer{}
+	if _, err := p.String(ast); !errors.Is(err, ErrInvalidCall) {
+		t.Errorf("expected ErrInvalidCall, got %v", err)
+	}
+}
+
+// TestNumbers - go accepts digit separators, so they are kept.
+func TestNumbers(t *testing.T) {
+	got, err := compile(t, "Def[N = 1_000]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "1_000") {
+		t.Errorf("got:\n%s", got)
+	}
+	if _, err := goparser.ParseFile(token.NewFileSet(), "main.go", got, 0); err != nil {
+		t.Errorf("%s\n%s", err, got)
+	}
+}
+
+func TestHashMap(t *testing.T) {
+	for src, want := range map[string]string{
+		"Def[M = HashMap[]]":               "var M = map[interface{}]interface{}{}",
+		"Def[M = HashMap[\"a\", 1, 2, x]]": "var M = map[interface{}]interface{}{\"a\": 1, 2: x}",
+	} {
+		got, err := compile(t, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(got, want) {
+			t.Errorf("%q: got:\n%s\nwant it to contain %s", src, got, want)
+		}
+		if _, err := goparser.ParseFile(token.NewFileSet(), "main.go", got, 0); err != nil {
+			t.Errorf("%q: output is not valid go: %s", src, err)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package javascript
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fuale/eicg/internal/parser"
+)
+
+var ErrInvalidCall = errors.New("invalid call")
+
+// Printer - prints AST as a JavaScript program.
+//
+// When `TypeScript` is set, parameters and constants get type annotations,
+// which makes output a valid TypeScript. Types are inferred only from
+// literals, everything else is `any`, so users can refine them later.
+type Printer struct {
+	TypeScript bool
+
+	usingAssocBuiltin bool
+	usingPrintBuiltin bool
+
+	// err - is the first error occurred while printing.
+	// Printing continues after the error, but the output is discarded.
+	err error
+
+	// location - is the location of the top-level expression being printed, reported with recovered panics.
+	location parser.Location
+}
+
+// String - prints the AST as a javascript, or typescript, program.
+//
+// Unexpected AST, which isn't produced by the parser, may panic the printer.
+// The panic is returned as `parser.ErrInternal` with the location of the top-level expression.
+func (p *Printer) String(ast parser.Statement) (out string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			out, err = "", fmt.Errorf("%w: %v near %s", parser.ErrInternal, r, p.location.String())
+		}
+	}()
+
+	st := p.printStatement(ast)
+	if p.err != nil {
+		return "", p.err
+	}
+
+	if p.usingAssocBuiltin {
+		st = fmt.Sprintf("%s\n%s", p.printAssocBuiltin(), st)
+	}
+	if p.usingPrintBuiltin {
+		st = fmt.Sprintf("%s\n%s", p.printPrintBuiltin(), st)
+	}
+	return st, nil
+}
+
+// errorf - records the first error and returns a placeholder
+// so callers don't have to check for errors on every step.
+func (p *Printer) errorf(format string, a ...any) string {
+	if p.err == nil {
+		p.err = fmt.Errorf("%w: %s", ErrInvalidCall, fmt.Sprintf(format, a...))
+	}
+
+	return "<error>"
+}
+
+func (p *Printer) printStatement(s parser.Statement) string {
+	switch s := s.(type) {
+	case parser.BlockStatement:
+		expressions := make([]string, 0)
+		for _, ee := range s.Expressions {
+			p.location = parser.LocationOf(ee)
+			expressions = append(expressions, p.printExpression(ee)+";")
+		}
+		return strings.Join(expressions, "\n")
+	default:
+		return p.errorf("unsupported statement")
+	}
+}
+
+func (p *Printer) printExpression(e parser.Expression) string {
+	switch e := e.(type) {
+	case parser.CallExpression:
+		// Definitions print their arguments on their own,
+		// so we handle them before printing arguments as expressions.
+		if e.Call == "Def" {
+			return p.printDef(e)
+		}
+
+		if e.Call == "Let" {
+			// The last argument is the body, so there must be at least one
+			if len(e.Args) == 0 {
+				return p.errorf("Let requires at least one argument: the body")
+			}
+
+			params := p.printParams(e.Args[:len(e.Args)-1])
+			return fmt.Sprintf("((%s)%s => %s)", strings.Join(params, ", "), p.annotate("any"), p.printExpression(e.Args[len(e.Args)-1]))
+		}
+
+		args := make([]string, 0)
+		for _, a := range e.Args {
+			args = append(args, p.printExpression(a))
+		}
+
+		if e.Call == "Print" {
+			p.usingPrintBuiltin = true
+			return fmt.Sprintf("builtin__print(%s)", strings.Join(args, ", "))
+		}
+
+		// HashMap[k1, v1, k2, v2] - is an object literal of key and value pairs.
+		// Keys are computed, so a name is a variable, not a property name.
+		if e.Call == "HashMap" {
+			if len(args)%2 != 0 {
+				return p.errorf("HashMap requires pairs of key and value")
+			}
+
+			pairs := make([]string, 0, len(args)/2)
+			for i := 0; i < len(args); i += 2 {
+				pairs = append(pairs, fmt.Sprintf("[%s]: %s", args[i], args[i+1]))
+			}
+
+			return fmt.Sprintf("({%s})", strings.Join(pairs, ", "))
+		}
+
+		if e.Call == "Map" {
+			if len(args) != 2 {
+				return p.errorf("Map accepts exactly two arguments: function and list")
+			}
+			return fmt.Sprintf("%s.map(%s)", args[1], args[0])
+		}
+
+		if e.Call == "List" {
+			return fmt.Sprintf("[%s]", strings.Join(args, ", "))
+		}
+
+		if e.Call == "Call" {
+			if len(args) == 0 {
+				return p.errorf("Call requires at least one argument: the function")
+			}
+			return fmt.Sprintf("((%s)(%s))", args[0], strings.Join(args[1:], ", "))
+		}
+
+		if e.Call == "Assoc" {
+			if len(args) != 3 {
+				return p.errorf("Assoc accepts exactly three arguments: key, value and object")
+			}
+			p.usingAssocBuiltin = true
+			return fmt.Sprintf("builtin__assoc(%s, %s, %s)", args[0], args[1], args[2])
+		}
+
+		if e.Call == "Has" {
+			if len(args) != 2 {
+				return p.errorf("Has accepts exactly two arguments: key and object")
+			}
+			return fmt.Sprintf("(%s in %s)", args[0], args[1])
+		}
+
+		if e.Call == "Get" {
+			if len(args) != 2 {
+				return p.errorf("Get accepts exactly two arguments: key and object")
+			}
+			return fmt.Sprintf("%s[%s]", args[1], args[0])
+		}
+
+		if e.Call == "Eq" {
+			if len(args) != 2 {
+				return p.errorf("Eq accepts exactly two arguments")
+			}
+			return fmt.Sprintf("(%s === %s)", args[0], args[1])
+		}
+
+		if e.Call == "Cond" {
+			// Cond[t1, e1, t2, e2, ..., default] - pairs of test and result, then default.
+			// Conditional operator is right-associative, so the chain needs no parentheses inside.
+			if len(args) < 3 || len(args)%2 == 0 {
+				return p.errorf("Cond requires an odd number of arguments, at least three")
+			}
+
+			branches := make([]string, 0, len(args)/2)
+			for i := 0; i < len(args)-1; i += 2 {
+				branches = append(branches, fmt.Sprintf("%s ? %s : ", args[i], args[i+1]))
+			}
+
+			return fmt.Sprintf("(%s%s)", strings.Join(branches, ""), args[len(args)-1])
+		}
+
+		if e.Call == "Inc" {
+			for i := range args {
+				args[i] = fmt.Sprintf("(%s+1)", args[i])
+			}
+			return strings.Join(args, ",")
+		}
+
+		callee := e.Call
+		if e.Callee != nil {
+			callee = p.printExpression(e.Callee)
+		} else if e.Call == "" {
+			return p.errorf("call has neither a name nor a callee at %s", e.Location)
+		}
+
+		return fmt.Sprintf("%s(%s)", callee, strings.Join(args, ", "))
+	case parser.LiteralNumberExpression:
+		return NormalizeNumber(e.Value)
+	case parser.LiteralStringExpression:
+		return fmt.Sprintf("\"%s\"", e.Value)
+	case parser.LiteralCharExpression:
+		// JavaScript has no chars, so it is a one-rune string
+		return fmt.Sprintf("'%s'", e.Value)
+	case parser.VariableReferenceExpression:
+		return e.Value
+	case parser.MemberExpression:
+		return fmt.Sprintf("%s.%s", p.printExpression(e.Object), e.Property)
+	}
+
+	return p.errorf("unsupported expression at %s", parser.LocationOf(e))
+}
+
+// printDef - prints `Def[name, Args[...], body]` as an arrow function,
+// and `Def[name = value]` as a constant.
+func (p *Printer) printDef(e parser.CallExpression) string {
+	if len(e.Args) == 0 {
+		return p.errorf("Def requires a name at %s", e.Location)
+	}
+
+	if defname, ok := e.Args[0].(parser.VariableReferenceExpression); ok {
+		if len(e.Args) < 3 {
+			return p.errorf("Def of %s requires parameters and a body at %s", defname.Value, e.Location)
+		}
+
+		params := make([]string, 0)
+		if paramDef, ok := e.Args[1].(parser.CallExpression); ok && paramDef.Call == "Args" {
+			params = p.printParams(paramDef.Args)
+		}
+
+		return fmt.Sprintf("const %s = (%s)%s => %s", defname.Value, strings.Join(params, ", "), p.annotate("any"), p.printExpression(e.Args[2]))
+	}
+
+	if a, ok := e.Args[0].(parser.AssignmentExpression); ok {
+		name, ok := a.Lhs.(parser.VariableReferenceExpression)
+		if !ok {
+			return p.errorf("Def can assign only to a name at %s", a.Location)
+		}
+		return fmt.Sprintf("const %s%s = %s", name.Value, p.annotate(p.typeOf(a.Rhs)), p.printExpression(a.Rhs))
+	}
+
+	return p.errorf("Def requires a name at %s", e.Location)
+}
+
+// printParams - prints arrow function parameters, which may be plain
+// names, nested `Args`, `HashMap[name]` or `name = default`.
+func (p *Printer) printParams(args []parser.Expression) []string {
+	params := make([]string, 0)
+
+	for _, arg := range args {
+		if argname, ok := arg.(parser.VariableReferenceExpression); ok {
+			params = append(params, argname.Value+p.annotate("any"))
+		} else if subargs, ok := arg.(parser.CallExpression); ok && subargs.Call == "Args" {
+			params = append(params, p.printParams(subargs.Args)...)
+		} else if subargs, ok := arg.(parser.CallExpression); ok && subargs.Call == "HashMap" {
+			if len(subargs.Args) != 1 {
+				params = append(params, p.errorf("HashMap currently accept only one argument"))
+			} else if name, ok := subargs.Args[0].(parser.VariableReferenceExpression); ok {
+				params = append(params, fmt.Sprintf("%s%s = {}", name.Value, p.annotate("any")))
+			} else {
+				params = append(params, p.errorf("HashMap accepts only a parameter name at %s", subargs.Location))
+			}
+		} else if a, ok := arg.(parser.AssignmentExpression); ok {
+			if name, ok := a.Lhs.(parser.VariableReferenceExpression); ok {
+				params = append(params, fmt.Sprintf("%s%s = %s", name.Value, p.annotate(p.typeOf(a.Rhs)), p.printExpression(a.Rhs)))
+			} else {
+				params = append(params, p.errorf("default value must be assigned to a parameter name at %s", a.Location))
+			}
+		} else {
+			params = append(params, p.errorf("parameter must be a name at %s", parser.LocationOf(arg)))
+		}
+	}
+
+	return params
+}
+
+// typeOf - infers TypeScript type of an expression. Only literals are known.
+func (p *Printer) typeOf(e parser.Expression) string {
+	switch e.(type) {
+	case parser.LiteralNumberExpression:
+		return "number"
+	case parser.LiteralStringExpression, parser.LiteralCharExpression:
+		return "string"
+	}
+
+	return "any"
+}
+
+// annotate - returns type annotation, but only when printing TypeScript.
+func (p *Printer) annotate(typ string) string {
+	if !p.TypeScript {
+		return ""
+	}
+
+	return ": " + typ
+}
+
+func (p *Printer) printAssocBuiltin() string {
+	return fmt.Sprintf("const builtin__assoc = (k%s, v%s, obj%s)%s => {\n  obj[k] = v;\n  return obj;\n};\n", p.annotate("any"), p.annotate("any"), p.annotate("any"), p.annotate("any"))
+}
+
+func (p *Printer) printPrintBuiltin() string {
+	return fmt.Sprintf("const builtin__print = (...args%s)%s => {\n  console.log(...args);\n  return args[0];\n};\n", p.annotate("any[]"), p.annotate("any"))
+}
+
+// NormalizeNumber - converts the lexed number to javascript's syntax.
+// `_` separators are valid since ES2021, so the number is kept as is.
+func NormalizeNumber(value string) string {
+	return value
+}
@@ -0,0 +1,123 @@
+package javascript
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fuale/eicg/internal/lexer"
+	"github.com/fuale/eicg/internal/parser"
+)
+
+// compile - prints the source with the given printer, returning the printer error.
+func compile(t *testing.T, p *Printer, src string) (string, error) {
+	t.Helper()
+
+	ast, err := parser.New(lexer.New(strings.NewReader(src))).Parse()
+	if err != nil {
+		t.Fatalf("parsing %q: %s", src, err)
+	}
+
+	return p.String(ast)
+}
+
+func TestProgram(t *testing.T) {
+	src := `
+Def[Square, Args[x], Mul[x, x]]
+Def[Limit = 10]
+Print[Map[Square, List[1, 2, 3]]]
+Print[Cond[Eq[Limit, 1], "one", Eq[Limit, 2], "two", "many"]]
+`
+	want := `const builtin__print = (...args) => {
+  console.log(...args);
+  return args[0];
+};
+
+const Square = (x) => Mul(x, x);
+const Limit = 10;
+builtin__print([1, 2, 3].map(Square));
+builtin__print(((Limit === 1) ? "one" : (Limit === 2) ? "two" : "many"));`
+
+	got, err := compile(t, &Printer{}, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestInvalid - covers programs which used to panic or exit the process,
+// for both javascript and typescript, which share the printer.
+func TestInvalid(t *testing.T) {
+	for _, typescript := range []bool{false, true} {
+		for _, src := range []string{
+			"Def[F, Args[HashMap[]], 1]",
+			"Def[F, Args[HashMap[a, b]], 1]",
+			"Def[F, Args[HashMap[1]], 1]",
+			"Def[F, Args[1], 1]",
+			"Print[Let[]]",
+			"Def[]",
+			"Def[F]",
+			"Def[1]",
+			"Print[Eq[1]]",
+			"Print[Map[]]",
+			"Print[Call[]]",
+			"Print[Assoc[1, 2]]",
+			"Print[Has[1]]",
+			"Print[Get[1]]",
+			"Print[Cond[1, 2]]",
+			"Print[HashMap[1]]",
+		} {
+			if _, err := compile(t, &Printer{TypeScript: typescript}, src); !errors.Is(err, ErrInvalidCall) {
+				t.Errorf("%q (typescript: %t): expected ErrInvalidCall, got %v", src, typescript, err)
+			}
+		}
+	}
+}
+
+func TestMember(t *testing.T) {
+	got, err := compile(t, &Printer{}, "Def[N = obj.name.first]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "const N = obj.name.first;"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMethodCall(t *testing.T) {
+	got, err := compile(t, &Printer{}, "xs.push[1]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "xs.push(1);"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestNumbers - javascript accepts digit separators, so they are kept.
+func TestNumbers(t *testing.T) {
+	got, err := compile(t, &Printer{}, "Def[N = 1_000]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "const N = 1_000;" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestHashMap(t *testing.T) {
+	for src, want := range map[string]string{
+		"Def[M = HashMap[]]":               "const M = ({});",
+		"Def[M = HashMap[\"a\", 1, k, 2]]": "const M = ({[\"a\"]: 1, [k]: 2});",
+	} {
+		got, err := compile(t, &Printer{}, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%q: got %q, want %q", src, got, want)
+		}
+	}
+}
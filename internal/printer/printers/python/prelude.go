@@ -0,0 +1,27 @@
+package python
+
+// prelude - is an ordered set of snippets (imports, builtin helpers),
+// which are emitted once at the top of the output. Snippets are kept
+// in order of their first use, so output is deterministic.
+type prelude struct {
+	snippets []string
+	seen     map[string]bool
+}
+
+// add - adds snippet to the set, unless it is already there.
+func (s *prelude) add(snippet string) {
+	if s.seen == nil {
+		s.seen = make(map[string]bool)
+	}
+
+	if s.seen[snippet] {
+		return
+	}
+
+	s.seen[snippet] = true
+	s.snippets = append(s.snippets, snippet)
+}
+
+func (s *prelude) empty() bool {
+	return len(s.snippets) == 0
+}
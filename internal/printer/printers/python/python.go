@@ -1,60 +1,251 @@
 package python
 
 import (
+	"errors"
 	"fmt"
 	"github.com/fuale/eicg/internal/parser"
-	"log"
 	"strings"
 )
 
+var ErrInvalidCall = errors.New("invalid call")
+
 type Printer struct {
-	usingAssocBuiltin bool
-	usingPrintBuiltin bool
+	// imports - are `import` statements, hoisted to the top of the output.
+	imports prelude
+
+	// helpers - are definitions of builtins, which can't be expressed inline.
+	helpers prelude
+
+	// err - is the first error occurred while printing.
+	// Printing continues after the error, but the output is discarded.
+	err error
+
+	// indent - is the nesting level of the function body being printed.
+	// Python is indentation-sensitive, so statements inside a `def`
+	// need to know how deep they are.
+	indent int
+
+	// lines - maps lines of the printed program body (without prelude),
+	// starting from 0, to the location of the top-level source expression.
+	lines map[int]parser.Location
+
+	// MatchStatement - lowers `Match` in statement position to `match`/`case`,
+	// which requires python 3.10. Otherwise, and always in expression position,
+	// `Match` is lowered to a chained ternary.
+	MatchStatement bool
+
+	// HelperPrefix - is prepended to names of emitted helpers, like `builtin__print`,
+	// to avoid clashes with user code. Empty means `DefaultHelperPrefix`.
+	HelperPrefix string
+
+	// Strict - makes calls to unknown functions an error, so a typo like `Pirnt[x]`
+	// doesn't silently become `Pirnt(x)`. Known functions are builtins, python builtins,
+	// and names defined with `Def`, `Class`, imports, parameters or bindings in scope.
+	Strict bool
+
+	// PlainPrint - prints `Print` as python's `print`, without the helper.
+	// The helper returns its first argument, so `Print` may be nested into expressions;
+	// with this option `Print[x]` gives `None` instead.
+	PlainPrint bool
+
+	// InlineAssoc - prints `Assoc[k, v, obj]` as `{**obj, k: v}` instead of calling the helper.
+	// It changes the semantics: the helper sets the key in place, while the inline form
+	// gives a new dict and leaves `obj` unchanged.
+	InlineAssoc bool
+
+	// Indent - is one level of indentation of nested blocks, e.g. "\t". Empty means two spaces.
+	// Helpers in the prelude are always indented with two spaces, which python allows,
+	// because every block may have its own indentation.
+	Indent string
+
+	// scopes - is a stack of names defined in enclosing functions, used in strict mode.
+	scopes []scope
+
+	// location - is the location of the top-level expression being printed, reported with recovered panics.
+	location parser.Location
+}
+
+const DefaultHelperPrefix = "builtin__"
+
+// String - prints the AST as a python program.
+//
+// Output is deterministic, the same AST always gives the same bytes: arguments,
+// including `HashMap` pairs and `Set` items, are printed in source order,
+// and prelude is in order of first use. Go maps are never iterated while printing.
+func (p *Printer) String(ast parser.Statement) (string, error) {
+	st, _, err := p.StringWithSourceMap(ast)
+	return st, err
 }
 
-func (p *Printer) String(ast parser.Statement) string {
+// StringWithSourceMap - is like `String`, but also maps every output line
+// (starting from 1) to the location of the top-level expression it was printed from.
+// Prelude lines (imports and helpers) have no source, so they are not mapped.
+//
+// Unexpected AST, which isn't produced by the parser, may panic the printer.
+// The panic is returned as `parser.ErrInternal` with the location of the top-level expression.
+func (p *Printer) StringWithSourceMap(ast parser.Statement) (out string, sourceMap map[int]parser.Location, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			out, sourceMap, err = "", nil, fmt.Errorf("%w: %v near %s", parser.ErrInternal, r, p.location.String())
+		}
+	}()
+
+	p.lines = make(map[int]parser.Location)
+
 	st := p.printStatement(ast)
-	if p.usingAssocBuiltin {
-		st = fmt.Sprintf("%s\n%s", p.printAssocBuiltin(), st)
+	if p.err != nil {
+		return "", nil, p.err
+	}
+
+	prefix := ""
+	if !p.helpers.empty() {
+		prefix = fmt.Sprintf("%s\n", strings.Join(p.helpers.snippets, "\n"))
+	}
+	if !p.imports.empty() {
+		prefix = fmt.Sprintf("%s\n\n%s", strings.Join(p.imports.snippets, "\n"), prefix)
+	}
+
+	offset := strings.Count(prefix, "\n")
+	sourceMap = make(map[int]parser.Location)
+	for line, location := range p.lines {
+		sourceMap[offset+line+1] = location
 	}
-	if p.usingPrintBuiltin {
-		st = fmt.Sprintf("%s\n%s", p.printPrintBuiltin(), st)
+
+	return prefix + st, sourceMap, nil
+}
+
+// errorf - records the first error and returns a placeholder
+// so callers don't have to check for errors on every step.
+func (p *Printer) errorf(format string, a ...any) string {
+	if p.err == nil {
+		p.err = fmt.Errorf("%w: %s", ErrInvalidCall, fmt.Sprintf(format, a...))
 	}
-	return st
+
+	return "<error>"
 }
 
 func (p *Printer) printStatement(s parser.Statement) string {
 	switch s := s.(type) {
 	case parser.BlockStatement:
+		// Top-level definitions are visible everywhere, because functions are called later
+		p.pushScope()
+		defer p.popScope()
+		p.declareStatements(s.Expressions)
+
 		expressions := make([]string, 0)
-		for _, ee := range s.Expressions {
-			expressions = append(expressions, p.printExpression(ee))
+		previousIsDefinition := false
+		previousIsComment := false
+		line := 0
+		for i, ee := range s.Expressions {
+			p.location = parser.LocationOf(ee)
+			lines := p.printBodyStatement(ee, false)
+			if len(lines) == 0 {
+				continue
+			}
+
+			// PEP8 wants two blank lines around top-level functions and classes.
+			// Comments stick to the definition below, so blank lines go before them.
+			isDefinition := p.isBlockDefinition(commented(s.Expressions[i:]))
+			_, isComment := ee.(parser.CommentExpression)
+			if len(expressions) > 0 && !previousIsComment && (isDefinition || previousIsDefinition) {
+				expressions = append(expressions, "", "")
+				line += 2
+			}
+
+			// Lines of nested blocks are already joined, so count them too
+			for _, l := range lines {
+				for i := 0; i <= strings.Count(l, "\n"); i++ {
+					p.lines[line] = parser.LocationOf(ee)
+					line++
+				}
+			}
+
+			expressions = append(expressions, lines...)
+			previousIsDefinition = isDefinition
+			previousIsComment = isComment
 		}
 		return strings.Join(expressions, "\n")
 	default:
-		return "<unknown>"
+		return p.errorf("unsupported statement")
 	}
 }
 
 func (p *Printer) printExpression(e parser.Expression) string {
 	switch e := e.(type) {
 	case parser.CallExpression:
-		args := make([]string, 0)
-		for _, a := range e.Args {
-			args = append(args, p.printExpression(a))
+		// Definitions and statements print their arguments on their own,
+		// so we handle them before printing arguments as expressions.
+		if e.Call == "Def" {
+			if len(e.Args) == 0 {
+				return p.errorf("Def requires a name at %s", e.Location)
+			}
+
+			if defname, ok := e.Args[0].(parser.VariableReferenceExpression); ok {
+				if len(e.Args) > 2 {
+					p.pushScope()
+					defer p.popScope()
+
+					params := make([]string, 0)
+					if paramDef, ok := e.Args[1].(parser.CallExpression); ok && paramDef.Call == "Args" {
+						p.declareParams(paramDef.Args)
+						params = p.printParams(paramDef.Args)
+					}
+
+					// Lambda can't contain statements, so `Do` body turns Def into a real function
+					if body, ok := e.Args[2].(parser.CallExpression); ok && body.Call == "Do" {
+						return p.printDef(defname.Value, params, body.Args)
+					}
+
+					return fmt.Sprintf("%s = lambda %s: %s", defname.Value, strings.Join(params, ", "), p.printExpression(e.Args[2]))
+				}
+
+				return p.errorf("Def of %s requires parameters and a body at %s", defname.Value, e.Location)
+			}
+
+			if a, ok := e.Args[0].(parser.AssignmentExpression); ok {
+				name, ok := a.Lhs.(parser.VariableReferenceExpression)
+				if !ok {
+					return p.errorf("Def can assign only to a name at %s", a.Location)
+				}
+				return fmt.Sprintf("%s = %s", name.Value, p.printExpression(a.Rhs))
+			}
+
+			return p.errorf("Def requires a name at %s", e.Location)
 		}
 
-		if e.Call == "Print" {
-			p.usingPrintBuiltin = true
-			return fmt.Sprintf("builtin__print(%s)", strings.Join(args, ","))
+		if e.Call == "Class" {
+			return p.printClass(e)
+		}
+
+		if e.Call == "Thread" {
+			return p.printExpression(p.thread(e))
+		}
+
+		if e.Call == "Try" || e.Call == "Raise" || e.Call == "Return" || e.Call == "Import" || e.Call == "ImportFrom" {
+			return p.errorf("%s is only valid in statement position", e.Call)
 		}
 
+		// Bindings are in scope of the body only, so arguments are printed inside the scope
 		if e.Call == "Let" {
+			// The last argument is the body, so there must be at least one
+			if len(e.Args) == 0 {
+				return p.errorf("Let requires at least one argument: the body")
+			}
+
+			p.pushScope()
+			defer p.popScope()
+			for _, binding := range e.Args[:len(e.Args)-1] {
+				p.declareParams([]parser.Expression{binding})
+			}
+
 			params := make([]string, 0)
 			l := len(e.Args) - 1
 			for i := 0; i < l; i++ {
 				if a, ok := e.Args[i].(parser.AssignmentExpression); ok {
-					variable := a.Lhs.(parser.VariableReferenceExpression)
+					variable, ok := a.Lhs.(parser.VariableReferenceExpression)
+					if !ok {
+						return p.errorf("Let can bind only a name at %s", a.Location)
+					}
 					value := p.printExpression(a.Rhs)
 					params = append(params, fmt.Sprintf("%s = %s", variable.Value, value))
 				}
@@ -66,11 +257,49 @@ func (p *Printer) printExpression(e parser.Expression) string {
 			return fmt.Sprintf("lambda %s: %s", strings.Join(params, ", "), p.printExpression(e.Args[len(e.Args)-1]))
 		}
 
+		if e.Call == "LetStar" {
+			return p.printLetStar(e)
+		}
+
+		if e.Call == "Do" {
+			return p.printDoExpression(e)
+		}
+
+		args := p.printArguments(e)
+
+		// Print[a, b] - prints arguments and returns the first one, so it may be used as a value:
+		// `Def[y = Print[5]]` prints 5 and binds it to `y`. `Let[y = Print[5], y]` is a function,
+		// which prints 5 once, when the `Let` itself is evaluated, and gives 5 when called.
+		// `Print[]` prints a blank line and returns `None`. See `PlainPrint` for a plain `print`.
+		if e.Call == "Print" {
+			if p.PlainPrint {
+				return fmt.Sprintf("print(%s)", strings.Join(args, ", "))
+			}
+			p.helpers.add(p.printPrintBuiltin())
+			return fmt.Sprintf("%s(%s)", p.helper("print"), strings.Join(args, ","))
+		}
+
+		// HashMap[k1, v1, k2, v2] - is a dict literal of key and value pairs
 		if e.Call == "HashMap" {
-			return "dict()"
+			if len(args) == 0 {
+				return "dict()"
+			}
+			if len(args)%2 != 0 {
+				return p.errorf("HashMap requires pairs of key and value")
+			}
+
+			pairs := make([]string, 0, len(args)/2)
+			for i := 0; i < len(args); i += 2 {
+				pairs = append(pairs, fmt.Sprintf("%s: %s", args[i], args[i+1]))
+			}
+
+			return fmt.Sprintf("{%s}", strings.Join(pairs, ", "))
 		}
 
 		if e.Call == "Map" {
+			if len(args) < 2 {
+				return p.errorf("Map requires a function and at least one list")
+			}
 			return fmt.Sprintf("map(%s, %s)", args[0], strings.Join(args[1:], ", "))
 		}
 
@@ -78,91 +307,917 @@ func (p *Printer) printExpression(e parser.Expression) string {
 			return fmt.Sprintf("[%s]", strings.Join(args, ", "))
 		}
 
+		if e.Call == "Set" {
+			// `{}` is an empty dict in python, so empty set needs a constructor
+			if len(args) == 0 {
+				return "set()"
+			}
+			return fmt.Sprintf("{%s}", strings.Join(args, ", "))
+		}
+
+		if e.Call == "Fmt" {
+			// Fmt[template, args...] - template uses python's `str.format` syntax
+			if positional(e.Args) == 0 {
+				return p.errorf("Fmt requires at least one argument: the template")
+			}
+			if _, ok := e.Args[0].(parser.AssignmentExpression); ok {
+				return p.errorf("Fmt template must be the first argument at %s", e.Location)
+			}
+			return fmt.Sprintf("%s.format(%s)", args[0], strings.Join(args[1:], ", "))
+		}
+
+		if e.Call == "Slice" {
+			// Slice[xs, start, stop] or Slice[xs, start, stop, step],
+			// where any bound may be `Nil` to leave its slot empty
+			if len(e.Args) != 3 && len(e.Args) != 4 {
+				return p.errorf("Slice accepts a sequence, start, stop and optional step")
+			}
+
+			bounds := make([]string, 0)
+			for i, a := range e.Args[1:] {
+				if v, ok := a.(parser.VariableReferenceExpression); ok && (v.Value == "Nil" || v.Value == "None") {
+					bounds = append(bounds, "")
+				} else {
+					bounds = append(bounds, args[i+1])
+				}
+			}
+
+			return fmt.Sprintf("%s[%s]", args[0], strings.Join(bounds, ":"))
+		}
+
 		if e.Call == "Call" {
+			if positional(e.Args) == 0 {
+				return p.errorf("Call requires at least one argument: the function")
+			}
 			return fmt.Sprintf("((%s)(%s))", args[0], strings.Join(args[1:], ","))
 		}
 
+		// Apply[f, xs] - calls the function with the list spread into positional arguments
+		if e.Call == "Apply" {
+			if len(args) != 2 {
+				return p.errorf("Apply accepts exactly two arguments: function and argument list")
+			}
+			return fmt.Sprintf("%s(*%s)", args[0], args[1])
+		}
+
+		// Partial[f, a, b] - fixes leading arguments of the function, handy with `Map`
+		if e.Call == "Partial" {
+			if positional(e.Args) == 0 {
+				return p.errorf("Partial requires at least one argument: the function")
+			}
+			p.imports.add("import functools")
+			return fmt.Sprintf("functools.partial(%s)", strings.Join(args, ", "))
+		}
+
+		// Compose[f, g, h] - is a function applying the arguments right to left: `f(g(h(x)))`
+		if e.Call == "Compose" {
+			if len(args) < 2 {
+				return p.errorf("Compose requires at least two functions")
+			}
+
+			// Functions are printed inside the lambda, so its parameter is prefixed like helpers,
+			// otherwise it would shadow a name used by them: `Compose[Partial[f, x], g]`
+			argument := p.helper(composeArgument)
+			body := argument
+			for i := len(args) - 1; i >= 0; i-- {
+				body = fmt.Sprintf("%s(%s)", args[i], body)
+			}
+
+			return fmt.Sprintf("(lambda %s: %s)", argument, body)
+		}
+
 		if e.Call == "Assoc" {
-			p.usingAssocBuiltin = true
-			return fmt.Sprintf("builtin__assoc(%s, %s, %s)", args[0], args[1], args[2])
+			if len(args) != 3 {
+				return p.errorf("Assoc accepts exactly three arguments: key, value and object")
+			}
+			if p.InlineAssoc {
+				return fmt.Sprintf("{**%s, %s: %s}", args[2], args[0], args[1])
+			}
+			p.helpers.add(p.printAssocBuiltin())
+			return fmt.Sprintf("%s(%s, %s, %s)", p.helper("assoc"), args[0], args[1], args[2])
+		}
+
+		// Update[k, f, obj] - replaces the existing value of the key with `f(value)`
+		if e.Call == "Update" {
+			if len(e.Args) != 3 {
+				return p.errorf("Update accepts exactly three arguments: key, function and object")
+			}
+			p.helpers.add(p.printUpdateBuiltin())
+			return fmt.Sprintf("%s(%s, %s, %s)", p.helper("update"), args[0], args[1], args[2])
+		}
+
+		// AssocIn[Path[a, b], v, obj] - sets a nested key, creating missing intermediate dicts
+		if e.Call == "AssocIn" {
+			if len(e.Args) != 3 {
+				return p.errorf("AssocIn accepts exactly three arguments: path, value and object")
+			}
+			p.helpers.add(p.printAssocInBuiltin())
+			return fmt.Sprintf("%s(%s, %s, %s)", p.helper("assoc_in"), args[0], args[1], args[2])
+		}
+
+		// GetIn[Path[a, b], obj] - is a nested lookup, which gives `None` when any key is missing
+		if e.Call == "GetIn" {
+			if len(e.Args) != 2 {
+				return p.errorf("GetIn accepts exactly two arguments: path and object")
+			}
+			p.helpers.add(p.printGetInBuiltin())
+			return fmt.Sprintf("%s(%s, %s)", p.helper("get_in"), args[0], args[1])
+		}
+
+		// Path[a, b] - is a path of keys for nested dicts
+		if e.Call == "Path" {
+			if len(args) == 0 {
+				return p.errorf("Path requires at least one key")
+			}
+			return fmt.Sprintf("[%s]", strings.Join(args, ", "))
 		}
 
 		if e.Call == "Has" {
-			p.usingAssocBuiltin = true
+			if len(args) != 2 {
+				return p.errorf("Has accepts exactly two arguments: key and object")
+			}
+			if !p.InlineAssoc {
+				p.helpers.add(p.printAssocBuiltin())
+			}
 			return fmt.Sprintf("(%s.get(%s, None) != None)", args[1], args[0])
 		}
 
+		// Get[key, dict] - is a dict lookup, which gives `None` for a missing key.
+		// Use `At` to index lists.
 		if e.Call == "Get" {
-			p.usingAssocBuiltin = true
+			if len(args) != 2 {
+				return p.errorf("Get accepts exactly two arguments: key and object")
+			}
+			if !p.InlineAssoc {
+				p.helpers.add(p.printAssocBuiltin())
+			}
 			return fmt.Sprintf("(%s.get(%s))", args[1], args[0])
 		}
 
-		if e.Call == "Cond" {
-			return fmt.Sprintf("%s if %s else %s", p.printExpression(e.Args[1]), p.printExpression(e.Args[0]), p.printExpression(e.Args[2]))
+		// At[xs, i] - is a plain subscription, it works for lists, strings and tuples,
+		// and raises on a missing index instead of giving `None`, unlike `Get`.
+		if e.Call == "At" {
+			if len(args) != 2 {
+				return p.errorf("At accepts exactly two arguments: sequence and index")
+			}
+			return fmt.Sprintf("%s[%s]", args[0], args[1])
 		}
 
-		if e.Call == "Def" {
-			if defname, ok := e.Args[0].(parser.VariableReferenceExpression); ok {
-				if len(e.Args) > 2 {
-					params := make([]string, 0)
-					if paramDef, ok := e.Args[1].(parser.CallExpression); ok && paramDef.Call == "Args" {
-						for _, arg := range paramDef.Args {
-							if argname, ok := arg.(parser.VariableReferenceExpression); ok {
-								params = append(params, argname.Value)
-							} else if subargs, ok := arg.(parser.CallExpression); ok && subargs.Call == "Args" {
-								subparams := make([]string, 0)
-								for _, ee := range subargs.Args {
-									subparams = append(subparams, p.printExpression(ee))
-								}
-								params = append(params, subparams...)
-							} else if subargs, ok := arg.(parser.CallExpression); ok && subargs.Call == "HashMap" {
-								if len(subargs.Args) > 1 {
-									log.Fatalf("HashMap currently accept only one argument")
-								} else {
-									params = append(
-										params,
-										fmt.Sprintf("%s = dict()", subargs.Args[0].(parser.VariableReferenceExpression).Value),
-									)
-								}
-							} else if a, ok := arg.(parser.AssignmentExpression); ok {
-								params = append(
-									params,
-									fmt.Sprintf("%s = %s", a.Lhs.(parser.VariableReferenceExpression).Value, p.printExpression(a.Rhs)),
-								)
-							}
-						}
-					}
+		// Keys[obj] and Values[obj] - are lists, not views, so they may be indexed and outlive changes of the dict
+		if e.Call == "Keys" || e.Call == "Values" {
+			if len(args) != 1 {
+				return p.errorf("%s accepts exactly one argument: the object", e.Call)
+			}
+			return fmt.Sprintf("list(%s.%s())", args[0], strings.ToLower(e.Call))
+		}
 
-					return fmt.Sprintf("%s = lambda %s: %s", defname.Value, strings.Join(params, ", "), p.printExpression(e.Args[2]))
-				}
+		// Merge[a, b, ...] - is a new dict, keys of later arguments win
+		if e.Call == "Merge" {
+			if len(args) < 2 {
+				return p.errorf("Merge requires at least two arguments")
 			}
 
-			if a, ok := e.Args[0].(parser.AssignmentExpression); ok {
-				return fmt.Sprintf("%s = %s", a.Lhs.(parser.VariableReferenceExpression).Value, p.printExpression(a.Rhs))
+			spread := make([]string, 0, len(args))
+			for _, a := range args {
+				spread = append(spread, "**"+a)
 			}
+
+			return fmt.Sprintf("{%s}", strings.Join(spread, ", "))
+		}
+
+		// Sort[xs] or Sort[xs, key] - is a new sorted list, the list itself isn't changed.
+		// Other options of `sorted` are keyword arguments: `Sort[xs, reverse = True]`.
+		if e.Call == "Sort" {
+			switch positional(e.Args) {
+			case 1:
+				return fmt.Sprintf("sorted(%s)", strings.Join(args, ", "))
+			case 2:
+				args[1] = "key=" + args[1]
+				return fmt.Sprintf("sorted(%s)", strings.Join(args, ", "))
+			}
+			return p.errorf("Sort accepts a sequence and an optional key function")
+		}
+
+		// Zip[a, b, ...] - is a list of tuples, as long as the shortest argument
+		if e.Call == "Zip" {
+			if len(args) < 2 {
+				return p.errorf("Zip requires at least two iterables")
+			}
+			return fmt.Sprintf("list(zip(%s))", strings.Join(args, ", "))
+		}
+
+		// Enumerate[xs] or Enumerate[xs, start] - is a list of (index, item) tuples
+		if e.Call == "Enumerate" {
+			if len(args) != 1 && len(args) != 2 {
+				return p.errorf("Enumerate accepts a sequence and an optional start index")
+			}
+			return fmt.Sprintf("list(enumerate(%s))", strings.Join(args, ", "))
+		}
+
+		// In[x, coll] - is a membership test for any collection, unlike `Has` which is meant for dict keys
+		if e.Call == "In" {
+			if len(args) != 2 {
+				return p.errorf("In accepts exactly two arguments: item and collection")
+			}
+			return fmt.Sprintf("(%s in %s)", args[0], args[1])
+		}
+
+		if e.Call == "Ne" {
+			if len(args) != 2 {
+				return p.errorf("Ne accepts exactly two arguments")
+			}
+			return fmt.Sprintf("(%s != %s)", args[0], args[1])
+		}
+
+		// Mod[a, b] and Pow[a, b] - are `%` and `**` operators
+		if e.Call == "Mod" || e.Call == "Pow" {
+			if len(args) != 2 {
+				return p.errorf("%s accepts exactly two arguments", e.Call)
+			}
+
+			operator := "%"
+			if e.Call == "Pow" {
+				operator = "**"
+			}
+
+			return fmt.Sprintf("(%s %s %s)", args[0], operator, args[1])
+		}
+
+		if e.Call == "Eq" {
+			if len(args) != 2 {
+				return p.errorf("Eq accepts exactly two arguments")
+			}
+			return fmt.Sprintf("(%s == %s)", args[0], args[1])
+		}
+
+		if e.Call == "Cond" {
+			// Cond[t1, e1, t2, e2, ..., default] - pairs of test and result, then default
+			if len(e.Args) < 3 || len(e.Args)%2 == 0 {
+				return p.errorf("Cond requires an odd number of arguments, at least three")
+			}
+
+			// Python's ternary is right-associative, so the chain needs no parentheses
+			branches := make([]string, 0)
+			for i := 0; i < len(e.Args)-1; i += 2 {
+				branches = append(branches, fmt.Sprintf("%s if %s", args[i+1], args[i]))
+			}
+
+			return fmt.Sprintf("%s else %s", strings.Join(branches, " else "), args[len(args)-1])
+		}
+
+		if e.Call == "Match" {
+			return p.printMatchExpression(e)
 		}
 
 		if e.Call == "Inc" {
+			if len(args) == 0 {
+				return p.errorf("Inc requires at least one argument")
+			}
+			// Parentheses keep the increment together inside other operators: `(x+1) ** 2`
 			for i := range args {
-				args[i] += "+1"
+				args[i] = fmt.Sprintf("(%s+1)", args[i])
 			}
 			return strings.Join(args, ",")
 		}
 
-		return fmt.Sprintf("%s(%s)", e.Call, strings.Join(args, ","))
+		callee := e.Call
+		if e.Callee != nil {
+			callee = p.printExpression(e.Callee)
+		} else if e.Call == "" {
+			return p.errorf("call has neither a name nor a callee at %s", e.Location)
+		} else if p.Strict && !p.defined(e.Call) {
+			return p.errorf("unknown function %s at %s", e.Call, e.Location.String())
+		}
+
+		return fmt.Sprintf("%s(%s)", callee, strings.Join(args, ", "))
 	case parser.LiteralNumberExpression:
+		return NormalizeNumber(e.Value)
+	case parser.LiteralStringExpression:
+		return fmt.Sprintf("\"%s\"", e.Value)
+	case parser.LiteralCharExpression:
+		// Python has no chars, so it is a one-rune string
+		return fmt.Sprintf("'%s'", e.Value)
+	case parser.VariableReferenceExpression:
 		return e.Value
+	case parser.MemberExpression:
+		return fmt.Sprintf("%s.%s", p.printExpression(e.Object), e.Property)
+	case parser.AssignmentExpression:
+		return p.errorf("assignment is not a value at %s, only statements and keyword arguments may assign", e.Location)
+	}
+
+	return p.errorf("unsupported expression at %s", parser.LocationOf(e))
+}
+
+// keywordBuiltins - are builtins, which pass keyword arguments through to python, like user functions do.
+var keywordBuiltins = map[string]bool{
+	"Call":      true,
+	"Enumerate": true,
+	"Fmt":       true,
+	"Partial":   true,
+	"Print":     true,
+	"Sort":      true,
+	"Zip":       true,
+}
+
+// printDoExpression - prints `Do` outside of a function body, where it is an expression,
+// so all arguments are evaluated in a tuple and the last one is taken.
+// Assignments can't be tuple elements, so they become walrus ones: `(a := 1)`.
+func (p *Printer) printDoExpression(e parser.CallExpression) string {
+	args := make([]string, 0, len(e.Args))
+	for _, a := range e.Args {
+		assignment, ok := a.(parser.AssignmentExpression)
+		if !ok {
+			args = append(args, p.printExpression(a))
+			continue
+		}
+
+		name, ok := assignment.Lhs.(parser.VariableReferenceExpression)
+		if !ok {
+			args = append(args, p.errorf("Do in expression position can assign only to a name at %s", assignment.Location))
+			continue
+		}
+
+		p.declare(name.Value)
+		args = append(args, fmt.Sprintf("(%s := %s)", name.Value, p.printExpression(assignment.Rhs)))
+	}
+
+	return fmt.Sprintf("(%s,)[-1]", strings.Join(args, ", "))
+}
+
+// printArguments - prints arguments of the call. Assignments are keyword arguments:
+// `Foo[x = 1]` is `Foo(x=1)`. Like in python, they must follow positional arguments,
+// and builtins, which have no python call to pass them to, don't accept them.
+func (p *Printer) printArguments(call parser.CallExpression) []string {
+	args := make([]string, 0, len(call.Args))
+	keyword := false
+	for _, a := range call.Args {
+		kw, ok := a.(parser.AssignmentExpression)
+		if !ok {
+			if keyword {
+				args = append(args, p.errorf("positional argument follows keyword argument at %s", parser.LocationOf(a)))
+				continue
+			}
+
+			args = append(args, p.printExpression(a))
+			continue
+		}
+
+		keyword = true
+		name, ok := kw.Lhs.(parser.VariableReferenceExpression)
+		if !ok {
+			args = append(args, p.errorf("keyword argument must be a name at %s", kw.Location))
+			continue
+		}
+
+		if call.Callee == nil && parser.IsBuiltin(call.Call) && !keywordBuiltins[call.Call] {
+			args = append(args, p.errorf("%s doesn't accept keyword arguments, given %s at %s", call.Call, name.Value, kw.Location))
+			continue
+		}
+
+		args = append(args, fmt.Sprintf("%s=%s", name.Value, p.printExpression(kw.Rhs)))
+	}
+
+	return args
+}
+
+// positional - counts arguments, which are not keyword ones.
+func positional(args []parser.Expression) int {
+	n := 0
+	for _, a := range args {
+		if _, ok := a.(parser.AssignmentExpression); !ok {
+			n++
+		}
+	}
+
+	return n
+}
+
+// printParams - prints `Args` list of a function definition. Parameter may be a plain name,
+// nested `Args`, `HashMap[name]` (defaults to empty dict), `Rest[name]` or `name = default`.
+func (p *Printer) printParams(args []parser.Expression) []string {
+	params := make([]string, 0)
+	for _, arg := range args {
+		if argname, ok := arg.(parser.VariableReferenceExpression); ok {
+			params = append(params, argname.Value)
+		} else if subargs, ok := arg.(parser.CallExpression); ok && subargs.Call == "Args" {
+			params = append(params, p.printParams(subargs.Args)...)
+		} else if subargs, ok := arg.(parser.CallExpression); ok && subargs.Call == "HashMap" {
+			if len(subargs.Args) != 1 {
+				params = append(params, p.errorf("HashMap currently accept only one argument"))
+			} else if name, ok := subargs.Args[0].(parser.VariableReferenceExpression); ok {
+				params = append(params, fmt.Sprintf("%s = dict()", name.Value))
+			} else {
+				params = append(params, p.errorf("HashMap accepts only a parameter name at %s", subargs.Location))
+			}
+		} else if rest, ok := arg.(parser.CallExpression); ok && rest.Call == "Rest" {
+			// Variadic parameter, collects all remaining arguments
+			if len(rest.Args) != 1 {
+				params = append(params, p.errorf("Rest accepts exactly one argument"))
+			} else if name, ok := rest.Args[0].(parser.VariableReferenceExpression); ok {
+				params = append(params, "*"+name.Value)
+			} else {
+				params = append(params, p.errorf("Rest accepts only a parameter name"))
+			}
+		} else if a, ok := arg.(parser.AssignmentExpression); ok {
+			if name, ok := a.Lhs.(parser.VariableReferenceExpression); ok {
+				params = append(params, fmt.Sprintf("%s = %s", name.Value, p.printExpression(a.Rhs)))
+			} else {
+				params = append(params, p.errorf("default value must be assigned to a parameter name at %s", a.Location))
+			}
+		} else {
+			params = append(params, p.errorf("parameter must be a name at %s", parser.LocationOf(arg)))
+		}
+	}
+
+	return params
+}
+
+// printDef - prints a function with statements body. Every statement goes
+// on its own line, and the value of the last one is returned.
+// Nested `Def`s are printed as nested functions one level deeper.
+// Function name is looked up when it is called, so the body may call it recursively.
+func (p *Printer) printDef(name string, params []string, body []parser.Expression) string {
+	p.declareStatements(body)
+	return fmt.Sprintf("def %s(%s):\n%s", name, strings.Join(params, ", "), p.printBlock(body, true))
+}
+
+// printBlock - prints statements one per line, one nesting level deeper.
+// When `ret` is set, the value of the last statement is returned.
+func (p *Printer) printBlock(body []parser.Expression, ret bool) string {
+	p.indent++
+	defer func() { p.indent-- }()
+
+	lines := make([]string, 0)
+	for i, stmt := range body {
+		lines = append(lines, p.printBodyStatement(stmt, ret && i == len(body)-1)...)
+	}
+
+	if len(lines) == 0 {
+		lines = append(lines, p.indentation()+"pass")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// printBodyStatement - prints expression in a statement position at the current
+// nesting level. When `ret` is set, the value of the statement is returned.
+func (p *Printer) printBodyStatement(e parser.Expression, ret bool) []string {
+	if c, ok := e.(parser.CommentExpression); ok {
+		return []string{p.indentation() + "#" + c.Text}
+	}
+
+	if c, ok := e.(parser.CallExpression); ok {
+		switch c.Call {
+		case "Try":
+			return []string{p.indentation() + p.printTry(c, ret)}
+		case "Import", "ImportFrom":
+			// Imports are hoisted, so nothing is left in place.
+			p.imports.add(p.printImport(c))
+			return []string{}
+		case "Return":
+			// Return[a, b] returns a tuple, which may be destructured by `a,b = F[]`
+			args := make([]string, 0)
+			for _, a := range c.Args {
+				args = append(args, p.printExpression(a))
+			}
+			return []string{strings.TrimRight(p.indentation()+"return "+strings.Join(args, ", "), " ")}
+		case "Raise":
+			// Nothing to return after raise, so `ret` doesn't matter here.
+			if len(c.Args) != 1 {
+				return []string{p.errorf("Raise accepts exactly one argument")}
+			}
+			return []string{p.indentation() + "raise " + p.printExpression(c.Args[0])}
+		case "Match":
+			if p.MatchStatement {
+				return []string{p.indentation() + p.printMatchStatement(c, ret)}
+			}
+		case "Def", "Class":
+			line := p.indentation() + p.printExpression(c)
+			if !ret {
+				return []string{line}
+			}
+
+			// Definition is a statement, so return it by name.
+			return []string{line, p.indentation() + "return " + p.defName(c)}
+		}
+	}
+
+	if a, ok := e.(parser.AssignmentExpression); ok {
+		target := p.printTarget(a.Lhs)
+		line := p.indentation() + fmt.Sprintf("%s = %s", target, p.printExpression(a.Rhs))
+		if !ret {
+			return []string{line}
+		}
+
+		return []string{line, p.indentation() + "return " + target}
+	}
+
+	line := p.printExpression(e)
+	if ret {
+		line = "return " + line
+	}
+
+	return []string{p.indentation() + line}
+}
+
+// printTarget - prints the left side of an assignment statement, which is
+// a name, or several names to destructure a tuple: `a, b = F()`.
+func (p *Printer) printTarget(e parser.Expression) string {
+	switch e := e.(type) {
 	case parser.VariableReferenceExpression:
 		return e.Value
+	case parser.TupleExpression:
+		names := make([]string, 0)
+		for _, element := range e.Elements {
+			names = append(names, p.printTarget(element))
+		}
+		return strings.Join(names, ", ")
+	}
+
+	return p.errorf("only names can be assigned")
+}
+
+// printTry - prints `Try[body, handler]` as a try/except statement.
+// Any exception is caught, and it is available in the handler as `e`.
+// Both body and handler may be a `Do` with several statements.
+func (p *Printer) printTry(e parser.CallExpression, ret bool) string {
+	if len(e.Args) != 2 {
+		return p.errorf("Try accepts exactly two arguments: body and handler")
 	}
 
-	return "<unknown>"
+	// Exception is bound until the end of the function, like any other name
+	p.declare("e")
+
+	return fmt.Sprintf(
+		"try:\n%s\n%sexcept Exception as e:\n%s",
+		p.printBlock(p.statements(e.Args[0]), ret),
+		p.indentation(),
+		p.printBlock(p.statements(e.Args[1]), ret),
+	)
+}
+
+// printLetStar - prints `LetStar[a = x, b = y, body]`, where every binding sees the previous ones,
+// as nested lambdas, which are called right away:
+//
+//	(lambda a: (lambda b: body)(y))(x)
+func (p *Printer) printLetStar(e parser.CallExpression) string {
+	if len(e.Args) == 0 {
+		return p.errorf("LetStar requires at least one argument: the body")
+	}
+
+	p.pushScope()
+	defer p.popScope()
+	p.declareParams(e.Args[:len(e.Args)-1])
+
+	body := p.printExpression(e.Args[len(e.Args)-1])
+	for i := len(e.Args) - 2; i >= 0; i-- {
+		a, ok := e.Args[i].(parser.AssignmentExpression)
+		if !ok {
+			return p.errorf("LetStar bindings must be assignments")
+		}
+
+		name, ok := a.Lhs.(parser.VariableReferenceExpression)
+		if !ok {
+			return p.errorf("LetStar can bind only names")
+		}
+
+		body = fmt.Sprintf("(lambda %s: %s)(%s)", name.Value, body, p.printExpression(a.Rhs))
+	}
+
+	return body
+}
+
+// thread - rewrites `Thread[x, f, g]` into `g[f[x]]`, so the value flows through steps left-to-right.
+// A step may be a function name, a method `obj.m`, or a call, which gets the value
+// as the first argument: `Thread[x, Add[1]]` is `Add[x, 1]`. Builtins work as steps too.
+func (p *Printer) thread(e parser.CallExpression) parser.Expression {
+	if len(e.Args) == 0 {
+		return parser.VariableReferenceExpression{Value: p.errorf("Thread requires at least one argument: the value")}
+	}
+
+	value := e.Args[0]
+	for _, step := range e.Args[1:] {
+		switch step := step.(type) {
+		case parser.VariableReferenceExpression:
+			value = parser.CallExpression{Call: step.Value, Args: []parser.Expression{value}, Location: step.Location}
+		case parser.MemberExpression:
+			value = parser.CallExpression{Callee: step, Args: []parser.Expression{value}, Location: step.Location}
+		case parser.CallExpression:
+			step.Args = append([]parser.Expression{value}, step.Args...)
+			value = step
+		default:
+			return parser.VariableReferenceExpression{Value: p.errorf("Thread steps must be functions")}
+		}
+	}
+
+	return value
+}
+
+// matchSubject - is the name the subject of `Match` is bound to, when it is not a plain name,
+// so it is evaluated only once. It is prefixed like helpers.
+const matchSubject = "subject"
+
+// composeArgument - is the parameter of the function made by `Compose`. It is prefixed like helpers.
+const composeArgument = "argument"
+
+// checkMatch - validates `Match[subject, pattern1, result1, ..., default]`.
+// Patterns may be literal numbers, strings or names. A name pattern
+// compares the subject with the value of the variable, it doesn't bind anything.
+func (p *Printer) checkMatch(e parser.CallExpression) bool {
+	if len(e.Args) < 4 || len(e.Args)%2 != 0 {
+		p.errorf("Match requires a subject, pairs of pattern and result, and a default")
+		return false
+	}
+
+	for i := 1; i < len(e.Args)-1; i += 2 {
+		switch e.Args[i].(type) {
+		case parser.LiteralNumberExpression, parser.LiteralStringExpression, parser.VariableReferenceExpression:
+		default:
+			p.errorf("Match patterns must be numbers, strings or names")
+			return false
+		}
+	}
+
+	return true
+}
+
+// printMatchExpression - prints `Match` as a chained ternary, comparing the subject with every pattern:
+//
+//	r1 if s == p1 else r2 if s == p2 else default
+//
+// Subject, which is not a plain name, is evaluated once by passing it to a lambda.
+func (p *Printer) printMatchExpression(e parser.CallExpression) string {
+	if !p.checkMatch(e) {
+		return "<error>"
+	}
+
+	subject, bound := p.helper(matchSubject), true
+	if v, ok := e.Args[0].(parser.VariableReferenceExpression); ok {
+		subject, bound = v.Value, false
+	}
+
+	branches := make([]string, 0)
+	for i := 1; i < len(e.Args)-1; i += 2 {
+		branches = append(branches, fmt.Sprintf(
+			"%s if %s == %s",
+			p.printExpression(e.Args[i+1]), subject, p.printExpression(e.Args[i]),
+		))
+	}
+
+	chain := fmt.Sprintf("%s else %s", strings.Join(branches, " else "), p.printExpression(e.Args[len(e.Args)-1]))
+	if bound {
+		return fmt.Sprintf("(lambda %s: %s)(%s)", subject, chain, p.printExpression(e.Args[0]))
+	}
+
+	return chain
+}
+
+// printMatchStatement - prints `Match` as a `match` statement. Literal patterns are
+// literal `case`s, while name patterns become guards, because a bare name in
+// `case` would capture the subject instead of comparing with it:
+//
+//	match s:
+//	  case 1:
+//	    r1
+//	  case _ if s == x:
+//	    r2
+//	  case _:
+//	    default
+//
+// Results may be `Do` with several statements, like in `Try`.
+func (p *Printer) printMatchStatement(e parser.CallExpression, ret bool) string {
+	if !p.checkMatch(e) {
+		return "<error>"
+	}
+
+	subject := p.printExpression(e.Args[0])
+	header := subject
+	if _, ok := e.Args[0].(parser.VariableReferenceExpression); !ok && p.hasNamePattern(e) {
+		// Guards need to refer to the subject, so it is bound to a name
+		subject = p.helper(matchSubject)
+		header = fmt.Sprintf("(%s := %s)", subject, header)
+	}
+
+	p.indent++
+	defer func() { p.indent-- }()
+
+	lines := []string{fmt.Sprintf("match %s:", header)}
+	for i := 1; i < len(e.Args)-1; i += 2 {
+		pattern := p.printExpression(e.Args[i])
+		if _, ok := e.Args[i].(parser.VariableReferenceExpression); ok {
+			pattern = fmt.Sprintf("_ if %s == %s", subject, pattern)
+		}
+
+		lines = append(lines,
+			fmt.Sprintf("%scase %s:", p.indentation(), pattern),
+			p.printBlock(p.statements(e.Args[i+1]), ret),
+		)
+	}
+
+	lines = append(lines,
+		fmt.Sprintf("%scase _:", p.indentation()),
+		p.printBlock(p.statements(e.Args[len(e.Args)-1]), ret),
+	)
+
+	return strings.Join(lines, "\n")
+}
+
+// hasNamePattern - tells whether any pattern of `Match` is a name.
+func (p *Printer) hasNamePattern(e parser.CallExpression) bool {
+	for i := 1; i < len(e.Args)-1; i += 2 {
+		if _, ok := e.Args[i].(parser.VariableReferenceExpression); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// printImport - prints `Import["module"]` as `import module`
+// and `ImportFrom["module", "name", ...]` as `from module import name, ...`.
+func (p *Printer) printImport(e parser.CallExpression) string {
+	names := make([]string, 0)
+	for _, a := range e.Args {
+		name, ok := a.(parser.LiteralStringExpression)
+		if !ok {
+			return p.errorf("%s accepts only strings", e.Call)
+		}
+		names = append(names, name.Value)
+	}
+
+	if e.Call == "Import" {
+		if len(names) != 1 {
+			return p.errorf("Import accepts exactly one argument")
+		}
+		return fmt.Sprintf("import %s", names[0])
+	}
+
+	if len(names) < 2 {
+		return p.errorf("ImportFrom accepts a module and at least one name")
+	}
+	return fmt.Sprintf("from %s import %s", names[0], strings.Join(names[1:], ", "))
+}
+
+// statements - unwraps `Do` into a list of statements, any other expression is a single statement.
+func (p *Printer) statements(e parser.Expression) []parser.Expression {
+	if do, ok := e.(parser.CallExpression); ok && do.Call == "Do" {
+		return do.Args
+	}
+
+	return []parser.Expression{e}
+}
+
+// printClass - prints `Class[name, Methods[Def[...], ...]]` as a python class.
+// Only methods are allowed in the body, and each of them must be a function
+// form of `Def` (with `Args`). Methods get `self` as the first parameter,
+// so it must not be listed in `Args`.
+func (p *Printer) printClass(e parser.CallExpression) string {
+	if len(e.Args) < 1 || len(e.Args) > 2 {
+		return p.errorf("Class accepts a name and optional Methods")
+	}
+
+	name, ok := e.Args[0].(parser.VariableReferenceExpression)
+	if !ok {
+		return p.errorf("Class name must be a name")
+	}
+
+	methods := make([]parser.Expression, 0)
+	if len(e.Args) == 2 {
+		m, ok := e.Args[1].(parser.CallExpression)
+		if !ok || m.Call != "Methods" {
+			return p.errorf("Class body must be Methods")
+		}
+		methods = m.Args
+	}
+
+	p.indent++
+	defer func() { p.indent-- }()
+
+	lines := make([]string, 0)
+	for _, method := range methods {
+		def, ok := method.(parser.CallExpression)
+		if !ok || def.Call != "Def" || len(def.Args) < 3 {
+			return p.errorf("Methods accepts only function definitions")
+		}
+
+		defname, ok := def.Args[0].(parser.VariableReferenceExpression)
+		if !ok {
+			return p.errorf("method name must be a name")
+		}
+
+		p.pushScope()
+		p.declare("self")
+
+		params := []string{"self"}
+		if paramDef, ok := def.Args[1].(parser.CallExpression); ok && paramDef.Call == "Args" {
+			p.declareParams(paramDef.Args)
+			params = append(params, p.printParams(paramDef.Args)...)
+		}
+
+		lines = append(lines, p.indentation()+p.printDef(defname.Value, params, p.statements(def.Args[2])))
+		p.popScope()
+	}
+
+	if len(lines) == 0 {
+		lines = append(lines, p.indentation()+"pass")
+	}
+
+	return fmt.Sprintf("class %s:\n%s", name.Value, strings.Join(lines, "\n"))
+}
+
+// commented - returns the first expression, which is not a comment,
+// that is the one preceding comments are attached to.
+func commented(expressions []parser.Expression) parser.Expression {
+	for _, e := range expressions {
+		if _, ok := e.(parser.CommentExpression); !ok {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// isBlockDefinition - tells whether expression is printed as a `def` or `class`
+// statement, rather than a one-line lambda assignment.
+func (p *Printer) isBlockDefinition(e parser.Expression) bool {
+	c, ok := e.(parser.CallExpression)
+	if !ok {
+		return false
+	}
+
+	if c.Call == "Class" {
+		return true
+	}
+
+	if c.Call == "Def" && len(c.Args) > 2 {
+		body, ok := c.Args[2].(parser.CallExpression)
+		return ok && body.Call == "Do"
+	}
+
+	return false
+}
+
+// defName - returns the name defined by `Def`, both in function and value forms.
+func (p *Printer) defName(def parser.CallExpression) string {
+	if len(def.Args) > 0 {
+		if name, ok := def.Args[0].(parser.VariableReferenceExpression); ok {
+			return name.Value
+		}
+		if a, ok := def.Args[0].(parser.AssignmentExpression); ok {
+			if name, ok := a.Lhs.(parser.VariableReferenceExpression); ok {
+				return name.Value
+			}
+		}
+	}
+
+	return "None"
+}
+
+// indentation - returns the leading whitespace for the current nesting level.
+func (p *Printer) indentation() string {
+	if p.Indent == "" {
+		return strings.Repeat("  ", p.indent)
+	}
+	return strings.Repeat(p.Indent, p.indent)
+}
+
+// helper - returns the prefixed name of a helper.
+func (p *Printer) helper(name string) string {
+	if p.HelperPrefix == "" {
+		return DefaultHelperPrefix + name
+	}
+
+	return p.HelperPrefix + name
 }
 
 func (p *Printer) printAssocBuiltin() string {
-	return "def builtin__assoc(k, v, obj):\n  obj[k] = v\n  return obj\n"
+	return fmt.Sprintf("def %s(k, v, obj):\n  obj[k] = v\n  return obj\n", p.helper("assoc"))
+}
+
+func (p *Printer) printAssocInBuiltin() string {
+	return fmt.Sprintf(
+		"def %s(path, v, obj):\n  node = obj\n  for k in path[:-1]:\n    node = node.setdefault(k, dict())\n  node[path[-1]] = v\n  return obj\n",
+		p.helper("assoc_in"),
+	)
+}
+
+func (p *Printer) printGetInBuiltin() string {
+	return fmt.Sprintf(
+		"def %s(path, obj):\n  for k in path:\n    if not isinstance(obj, dict) or k not in obj:\n      return None\n    obj = obj[k]\n  return obj\n",
+		p.helper("get_in"),
+	)
+}
+
+func (p *Printer) printUpdateBuiltin() string {
+	return fmt.Sprintf("def %s(k, f, obj):\n  obj[k] = f(obj[k])\n  return obj\n", p.helper("update"))
 }
 
 func (p *Printer) printPrintBuiltin() string {
-	return "def builtin__print(*args, **kwargs):\n  print(*args, **kwargs)\n  return args[0]\n"
+	return fmt.Sprintf("def %s(*args, **kwargs):\n  print(*args, **kwargs)\n  return args[0] if args else None\n", p.helper("print"))
+}
+
+// NormalizeNumber - converts the lexed number to python's syntax.
+// Python accepts every form the lexer does, including `_` separators, so it is kept as is.
+func NormalizeNumber(value string) string {
+	return value
 }
@@ -0,0 +1,796 @@
+package python
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/fuale/eicg/internal/lexer"
+	"github.com/fuale/eicg/internal/parser"
+)
+
+// compile - prints the source with the given printer, failing the test on error.
+func compile(t *testing.T, p *Printer, src string) string {
+	t.Helper()
+
+	out, err := compileErr(t, p, src)
+	if err != nil {
+		t.Fatalf("printing %q: %s", src, err)
+	}
+
+	return out
+}
+
+// compileErr - is like `compile`, but returns the printer error.
+// Parse errors still fail the test, because they are not what is tested.
+func compileErr(t *testing.T, p *Printer, src string) (string, error) {
+	t.Helper()
+
+	ast, err := parser.New(lexer.New(strings.NewReader(src))).Parse()
+	if err != nil {
+		t.Fatalf("parsing %q: %s", src, err)
+	}
+
+	return p.String(ast)
+}
+
+// expectPython - compiles the source with the default printer and compares the output.
+func expectPython(t *testing.T, src, want string) {
+	t.Helper()
+
+	if got := compile(t, &Printer{}, src); got != want {
+		t.Errorf("%q:\ngot:\n%s\nwant:\n%s", src, got, want)
+	}
+}
+
+// expectInvalid - compiles the source, which must fail with `ErrInvalidCall`.
+func expectInvalid(t *testing.T, src string) {
+	t.Helper()
+
+	_, err := compileErr(t, &Printer{}, src)
+	if !errors.Is(err, ErrInvalidCall) {
+		t.Errorf("%q: expected ErrInvalidCall, got %v", src, err)
+	}
+}
+
+func TestEq(t *testing.T) {
+	expectPython(t, "Def[Same, Args[x], x == 1]", "Same = lambda x: (x == 1)")
+	expectPython(t, "Def[Same, Args[x], Eq[x, 1]]", "Same = lambda x: (x == 1)")
+
+	expectInvalid(t, "Print[Eq[1]]")
+	expectInvalid(t, "Print[Eq[1, 2, 3]]")
+}
+
+func TestKeywordArguments(t *testing.T) {
+	expectPython(t, "Range[start = 1, stop = 10]", "Range(start=1, stop=10)")
+	expectPython(t, "Print[Range[1, stop = 10]]", "def builtin__print(*args, **kwargs):\n  print(*args, **kwargs)\n  return args[0] if args else None\n\nbuiltin__print(Range(1, stop=10))")
+
+	tests := []struct {
+		src, want string
+	}{
+		{`Print[1, 2, sep = "-"]`, `builtin__print(1,2,sep="-")`},
+		{`Def[F = Partial[int, base = 2]]`, `F = functools.partial(int, base=2)`},
+		{`Def[F = Sort[xs, reverse = True]]`, `F = sorted(xs, reverse=True)`},
+		{`Def[F = Sort[xs, len, reverse = True]]`, `F = sorted(xs, key=len, reverse=True)`},
+		{`Def[F = Enumerate[xs, start = 1]]`, `F = list(enumerate(xs, start=1))`},
+		{`Def[F = Fmt["{a}", a = 1]]`, `F = "{a}".format(a=1)`},
+		{`Def[F = Call[f, x = 1]]`, `F = ((f)(x=1))`},
+	}
+	for _, tt := range tests {
+		if got := compile(t, &Printer{}, tt.src); !strings.HasSuffix(got, tt.want) {
+			t.Errorf("%q: got:\n%s\nwant it to end with:\n%s", tt.src, got, tt.want)
+		}
+	}
+
+	for _, src := range []string{
+		"Print[List[x = 1]]",
+		"Print[Eq[a = 1, 2]]",
+		"Print[HashMap[k = 1]]",
+		"Print[x = 1, 2]",
+		"Foo[x = 1, 2]",
+		"Def[F, Args[], Do[Return[x = 1]]]",
+	} {
+		expectInvalid(t, src)
+	}
+}
+
+// TestNoPlaceholders - invalid programs must give an error rather than a placeholder in the output.
+func TestNoPlaceholders(t *testing.T) {
+	for _, src := range []string{
+		`Print[1, 2, sep = "-"]`,
+		"Print[List[x = 1]]",
+		"Def[F, Args[Args[x = 1]], x]",
+	} {
+		out, err := compileErr(t, &Printer{}, src)
+		if err == nil && (strings.Contains(out, "<unknown>") || strings.Contains(out, "<error>")) {
+			t.Errorf("%q: placeholder in the output:\n%s", src, out)
+		}
+	}
+}
+
+func TestRest(t *testing.T) {
+	expectPython(t, "Def[F, Args[a, Rest[xs]], xs]", "F = lambda a, *xs: xs")
+	expectPython(t, "Def[F, Args[a, Rest[xs]], Do[Print[a]]]", "def builtin__print(*args, **kwargs):\n  print(*args, **kwargs)\n  return args[0] if args else None\n\ndef F(a, *xs):\n  return builtin__print(a)")
+
+	expectInvalid(t, "Def[F, Args[Rest[]], 1]")
+	expectInvalid(t, "Def[F, Args[Rest[a, b]], 1]")
+	expectInvalid(t, "Def[F, Args[Rest[1]], 1]")
+}
+
+func TestNestedDef(t *testing.T) {
+	expectPython(t, "Def[Outer, Args[x], Do[Def[Inner, Args[y], Do[y]], Inner[x]]]", "def Outer(x):\n  def Inner(y):\n    return y\n  return Inner(x)")
+	expectPython(t, "Def[Outer, Args[], Do[Def[Inner, Args[y], y]]]", "def Outer():\n  Inner = lambda y: y\n  return Inner")
+}
+
+func TestDoExpression(t *testing.T) {
+	expectPython(t, "Def[F = Do[1, 2]]", "F = (1, 2,)[-1]")
+	expectPython(t, "Def[F = Do[a = 1, b = a, b]]", "F = ((a := 1), (b := a), b,)[-1]")
+	expectPython(t, "Def[F, Args[x], Let[y = Do[z = x, z], y]]", "F = lambda x: lambda y = ((z := x), z,)[-1]: y")
+
+	expectInvalid(t, "Def[F = Do[a,b = Pair[], a]]")
+}
+
+func TestClass(t *testing.T) {
+	expectPython(t, "Class[Point, Methods[Def[Norm, Args[x], x]]]", "class Point:\n  def Norm(self, x):\n    return x")
+	expectPython(t, "Class[Point]", "class Point:\n  pass")
+
+	for _, src := range []string{
+		"Class[]",
+		"Class[1]",
+		"Class[Point, List[]]",
+		"Class[Point, Methods[1]]",
+		"Class[Point, Methods[Def[Norm = 1]]]",
+		"Class[Point, Methods[]] Class[A, B, C]",
+	} {
+		expectInvalid(t, src)
+	}
+}
+
+// printPrelude - is the helper, which every program using `Print` starts with.
+const printPrelude = "def builtin__print(*args, **kwargs):\n  print(*args, **kwargs)\n  return args[0] if args else None\n\n"
+
+func TestTry(t *testing.T) {
+	expectPython(t, "Try[Risky[], Print[e]]", printPrelude+"try:\n  Risky()\nexcept Exception as e:\n  builtin__print(e)")
+	expectPython(t, "Def[F, Args[], Do[Try[Risky[], Print[e]]]]", printPrelude+"def F():\n  try:\n    return Risky()\n  except Exception as e:\n    return builtin__print(e)")
+
+	expectInvalid(t, "Try[Risky[]]")
+	expectInvalid(t, "Print[Try[Risky[], 1]]")
+}
+
+func TestRaise(t *testing.T) {
+	expectPython(t, "Raise[value]", "raise value")
+	expectPython(t, "Def[F, Args[x], Do[Raise[x]]]", "def F(x):\n  raise x")
+
+	expectInvalid(t, "Def[F, Args[], Do[Raise[]]]")
+	expectInvalid(t, "Raise[a, b]")
+	expectInvalid(t, "Print[Raise[1]]")
+}
+
+func TestImport(t *testing.T) {
+	expectPython(t, "Import[\"os\"]\nPrint[os]", "import os\n\n"+printPrelude+"builtin__print(os)")
+	expectPython(t, "ImportFrom[\"os\", \"path\", \"sep\"]\nDef[P = path]", "from os import path, sep\n\nP = path")
+
+	// Imports are hoisted out of function bodies, and each is emitted once
+	expectPython(t, "Def[F, Args[], Do[Import[\"os\"], os]]\nImport[\"os\"]", "import os\n\ndef F():\n  return os")
+
+	for _, src := range []string{
+		"Import[]",
+		"Import[\"os\", \"sys\"]",
+		"Import[os]",
+		"ImportFrom[\"os\"]",
+		"Print[Import[\"os\"]]",
+	} {
+		expectInvalid(t, src)
+	}
+}
+
+func TestPreludeOnce(t *testing.T) {
+	out := compile(t, &Printer{}, "Def[A = Assoc[1, 2, HashMap[]]]\nDef[B = Assoc[3, 4, A]]\nPrint[Assoc[5, 6, B]]")
+	if !strings.HasPrefix(out, "def builtin__assoc(") {
+		t.Errorf("helpers must be at the top:\n%s", out)
+	}
+	if n := strings.Count(out, "def builtin__assoc("); n != 1 {
+		t.Errorf("assoc helper is emitted %d times:\n%s", n, out)
+	}
+}
+
+func TestLet(t *testing.T) {
+	expectPython(t, "Def[F = Let[1]]", "F = lambda : 1")
+	expectPython(t, "Def[F = Let[y, x = 1, Call[x, y]]]", "F = lambda y, x = 1: ((x)(y))")
+
+	expectInvalid(t, "Let[]")
+	expectInvalid(t, "Print[Let[]]")
+}
+
+func TestCond(t *testing.T) {
+	expectPython(t, "Def[F = Cond[a, 1, 2]]", "F = 1 if a else 2")
+	expectPython(t, "Def[F = Cond[a, 1, b, 2, 3]]", "F = 1 if a else 2 if b else 3")
+
+	expectInvalid(t, "Print[Cond[]]")
+	expectInvalid(t, "Print[Cond[a, 1]]")
+	expectInvalid(t, "Print[Cond[a, 1, b, 2]]")
+}
+
+func TestSet(t *testing.T) {
+	expectPython(t, "Def[S = Set[1, 2, 3]]", "S = {1, 2, 3}")
+	expectPython(t, "Def[S = Set[]]", "S = set()")
+}
+
+func TestFmt(t *testing.T) {
+	expectPython(t, `Def[S = Fmt["Hello {}", name]]`, `S = "Hello {}".format(name)`)
+	expectPython(t, `Def[S = Fmt["Hello"]]`, `S = "Hello".format()`)
+
+	expectInvalid(t, "Print[Fmt[]]")
+	expectInvalid(t, "Print[Fmt[a = 1]]")
+}
+
+func TestMember(t *testing.T) {
+	expectPython(t, "Print[obj.name]", printPrelude+"builtin__print(obj.name)")
+	expectPython(t, "Def[N = HashMap[].keys]", "N = dict().keys")
+}
+
+func TestMethodCall(t *testing.T) {
+	expectPython(t, "xs.append[1]", "xs.append(1)")
+	expectPython(t, "Def[xs = List[]]\nxs.sort[key = len]", "xs = []\nxs.sort(key=len)")
+	expectPython(t, "Print[xs.pop[]]", printPrelude+"builtin__print(xs.pop())")
+}
+
+func TestSlice(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"Def[S = Slice[xs, 1, 3]]", "S = xs[1:3]"},
+		{"Def[S = Slice[xs, 1, 10, 2]]", "S = xs[1:10:2]"},
+		{"Def[S = Slice[xs, Nil, 3]]", "S = xs[:3]"},
+		{"Def[S = Slice[xs, 1, Nil]]", "S = xs[1:]"},
+		{"Def[S = Slice[xs, Nil, Nil, 2]]", "S = xs[::2]"},
+		{"Def[S = Slice[xs, None, n]]", "S = xs[:n]"},
+	}
+	for _, tt := range tests {
+		expectPython(t, tt.src, tt.want)
+	}
+
+	expectInvalid(t, "Print[Slice[xs]]")
+	expectInvalid(t, "Print[Slice[xs, 1]]")
+	expectInvalid(t, "Print[Slice[xs, 1, 2, 3, 4]]")
+}
+
+func TestAtAndGet(t *testing.T) {
+	// At is a subscription, which raises on a missing index
+	expectPython(t, "Def[X = At[xs, 0]]", "X = xs[0]")
+	expectPython(t, "Def[X = At[\"abc\", i]]", "X = \"abc\"[i]")
+
+	// Get is a lookup in a dict, which gives None for a missing key
+	if got := compile(t, &Printer{}, "Def[X = Get[\"k\", d]]"); !strings.HasSuffix(got, "X = (d.get(\"k\"))") {
+		t.Errorf("got:\n%s", got)
+	}
+
+	expectInvalid(t, "Print[At[xs]]")
+	expectInvalid(t, "Print[At[xs, 1, 2]]")
+	expectInvalid(t, "Print[Get[\"k\"]]")
+	expectInvalid(t, "Print[Get[\"k\", d, 1]]")
+}
+
+func TestBlankLinesAroundDefinitions(t *testing.T) {
+	expectPython(t, "Def[F, Args[], Do[1]]\nDef[G, Args[], Do[2]]", "def F():\n  return 1\n\n\ndef G():\n  return 2")
+	expectPython(t, "Def[A = 1]\nDef[F, Args[], Do[1]]\nDef[B = 2]", "A = 1\n\n\ndef F():\n  return 1\n\n\nB = 2")
+
+	// Lambdas are assignments, so they are not separated
+	expectPython(t, "Def[F, Args[x], x]\nDef[G, Args[x], x]", "F = lambda x: x\nG = lambda x: x")
+}
+
+func TestSourceMap(t *testing.T) {
+	src := "Def[A = 1]\n\nPrint[A]\nDef[F, Args[], Do[\n  Print[A],\n  A\n]]"
+	ast, err := parser.New(lexer.New(strings.NewReader(src))).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, sourceMap, err := (&Printer{}).StringWithSourceMap(ast)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every line of the expression maps to the start of the expression
+	wantRows := map[string]int{
+		"A = 1":               1,
+		"builtin__print(A)":   3,
+		"def F():":            4,
+		"  builtin__print(A)": 4,
+		"  return A":          4,
+	}
+	for i, line := range strings.Split(out, "\n") {
+		want, ok := wantRows[line]
+		if !ok {
+			continue
+		}
+		if got := sourceMap[i+1]; got.Row != want {
+			t.Errorf("line %d %q: got row %d, want %d", i+1, line, got.Row, want)
+		}
+		delete(wantRows, line)
+	}
+	if len(wantRows) > 0 {
+		t.Errorf("lines not found in the output: %v\n%s", wantRows, out)
+	}
+
+	// Prelude has no source
+	if _, ok := sourceMap[1]; ok {
+		t.Errorf("prelude line is mapped: %v", sourceMap[1])
+	}
+}
+
+func TestMatch(t *testing.T) {
+	// By default Match is a chained ternary, which works everywhere
+	expectPython(t, "Def[F, Args[x], Match[x, 1, \"one\", y, \"y\", \"other\"]]", "F = lambda x: \"one\" if x == 1 else \"y\" if x == y else \"other\"")
+	expectPython(t, "Def[F, Args[x], Match[G[x], 1, \"one\", \"other\"]]", "F = lambda x: (lambda builtin__subject: \"one\" if builtin__subject == 1 else \"other\")(G(x))")
+
+	// In a function body it may be a match statement, name patterns are guards
+	statement := &Printer{MatchStatement: true}
+	want := "def F(x):\n  match (builtin__subject := G(x)):\n    case 1:\n      return \"one\"\n    case _ if builtin__subject == y:\n      return \"y\"\n    case _:\n      return \"other\""
+	if got := compile(t, statement, "Def[F, Args[x], Do[Match[G[x], 1, \"one\", y, \"y\", \"other\"]]]"); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Match statement is only a statement, so in a value position it is a ternary
+	if got := compile(t, &Printer{MatchStatement: true}, "Def[F, Args[x], Match[x, 1, 2, 3]]"); got != "F = lambda x: 2 if x == 1 else 3" {
+		t.Errorf("got:\n%s", got)
+	}
+
+	for _, src := range []string{
+		"Print[Match[x, 1, 2]]",
+		"Print[Match[x, 1]]",
+		"Print[Match[x, List[], 1, 2]]",
+	} {
+		expectInvalid(t, src)
+	}
+}
+
+func TestThread(t *testing.T) {
+	expectPython(t, "Def[Y = Thread[x, F, G]]", "Y = G(F(x))")
+	expectPython(t, "Def[Y = Thread[x, F, G, H]]", "Y = H(G(F(x)))")
+
+	// Call steps get the value as the first argument, members are called with it
+	expectPython(t, "Def[Y = Thread[x, Inc, Pow[2], str.upper]]", "Y = str.upper(((x+1) ** 2))")
+	expectPython(t, "Def[Y = Thread[x]]", "Y = x")
+
+	expectInvalid(t, "Print[Thread[]]")
+	expectInvalid(t, "Print[Thread[x, 1]]")
+}
+
+func TestLetStar(t *testing.T) {
+	expectPython(t, "Def[F = LetStar[a = 1, b = Mul[a, 2], b]]", "F = (lambda a: (lambda b: b)(Mul(a, 2)))(1)")
+	expectPython(t, "Def[F = LetStar[1]]", "F = 1")
+
+	expectInvalid(t, "Print[LetStar[]]")
+	expectInvalid(t, "Print[LetStar[a, 1]]")
+}
+
+// runPython - runs the program with python3 and returns its output.
+// The test is skipped when python3 is not installed.
+func runPython(t *testing.T, program string) string {
+	t.Helper()
+
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 is not installed")
+	}
+
+	out, err := exec.Command(python, "-c", program).CombinedOutput()
+	if err != nil {
+		t.Fatalf("running:\n%s\n%s%s", program, out, err)
+	}
+
+	return string(out)
+}
+
+// TestPrintValue - `Print` gives its first argument, so it may be bound or nested.
+func TestPrintValue(t *testing.T) {
+	src := "Def[Y = Print[5]]\nPrint[Add[Y, 1]]\nPrint[Print[\"a\", \"b\"]]"
+	out := compile(t, &Printer{}, src)
+	if !strings.HasSuffix(out, "Y = builtin__print(5)\nbuiltin__print(Add(Y, 1))\nbuiltin__print(builtin__print(\"a\",\"b\"))") {
+		t.Fatalf("got:\n%s", out)
+	}
+
+	if got := runPython(t, "def Add(a, b):\n  return a + b\n\n"+out); got != "5\n6\na b\na\n" {
+		t.Errorf("got %q", got)
+	}
+
+	// Let is a function, its bindings are evaluated once, when the Let is, rather than on every call
+	src = "Def[F = Let[y = Print[5], y]]\nPrint[\"defined\"]\nPrint[F[]]\nPrint[F[]]\nPrint[Call[Let[y = Print[7], y]]]"
+	out = compile(t, &Printer{}, src)
+	if !strings.HasSuffix(out, "F = lambda y = builtin__print(5): y\nbuiltin__print(\"defined\")\nbuiltin__print(F())\nbuiltin__print(F())\nbuiltin__print(((lambda y = builtin__print(7): y)()))") {
+		t.Fatalf("got:\n%s", out)
+	}
+
+	if got := runPython(t, out); got != "5\ndefined\n5\n5\n7\n7\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+// TestEmptyPrint - `Print[]` prints a blank line and gives `None`, instead of failing at runtime.
+func TestEmptyPrint(t *testing.T) {
+	out := compile(t, &Printer{}, "Print[]\nDef[Y = Print[]]\nPrint[Y]")
+	if !strings.HasSuffix(out, "builtin__print()\nY = builtin__print()\nbuiltin__print(Y)") {
+		t.Fatalf("got:\n%s", out)
+	}
+
+	if got := runPython(t, out); got != "\n\nNone\n" {
+		t.Errorf("got %q", got)
+	}
+
+	if got := runPython(t, compile(t, &Printer{PlainPrint: true}, "Print[]")); got != "\n" {
+		t.Errorf("plain print: got %q", got)
+	}
+}
+
+func TestRecursion(t *testing.T) {
+	src := "Def[Factorial, Args[n], Do[Cond[n == 0, 1, Mul[n, Factorial[Sub[n, 1]]]]]]\nPrint[Factorial[5]]"
+	out := compile(t, &Printer{}, src)
+
+	want := "def Factorial(n):\n  return 1 if (n == 0) else Mul(n, Factorial(Sub(n, 1)))"
+	if !strings.Contains(out, want) {
+		t.Fatalf("got:\n%s\nwant it to contain:\n%s", out, want)
+	}
+
+	// Arithmetic is not a builtin, so it is defined by the program
+	prelude := "def Mul(a, b):\n  return a * b\n\ndef Sub(a, b):\n  return a - b\n\n"
+	if got := runPython(t, prelude+out); got != "120\n" {
+		t.Errorf("got %q, want 120", got)
+	}
+}
+
+func TestTuples(t *testing.T) {
+	expectPython(t, "Def[Pair, Args[], Do[Return[1, 2]]]", "def Pair():\n  return 1, 2")
+	expectPython(t, "Def[F, Args[], Do[a,b = Pair[], b]]", "def F():\n  a, b = Pair()\n  return b")
+	// A name before an assignment is a statement of its own, unless it is joined to it
+	expectPython(t, "Def[F, Args[x], Do[x, a = 1]]", "def F(x):\n  x\n  a = 1\n  return a")
+	expectPython(t, "Def[F, Args[], Do[a,b = Pair[]]]", "def F():\n  a, b = Pair()\n  return a, b")
+
+	out := compile(t, &Printer{}, "Def[Pair, Args[], Do[Return[1, 2]]]\nDef[F, Args[], Do[a,b = Pair[], Return[b, a]]]\nPrint[F[]]")
+	if got := runPython(t, out); got != "(2, 1)\n" {
+		t.Errorf("got %q, want (2, 1)", got)
+	}
+}
+
+func TestComments(t *testing.T) {
+	l := lexer.New(strings.NewReader("// Answer\nDef[A = 42]\n// Functions\nDef[F, Args[], Do[A]]"))
+	l.KeepComments = true
+	ast, err := parser.New(l).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := (&Printer{}).String(ast)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Comment sticks to the definition below, so blank lines go before it
+	if want := "# Answer\nA = 42\n\n\n# Functions\ndef F():\n  return A"; got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHelperPrefix(t *testing.T) {
+	p := &Printer{HelperPrefix: "eicg_"}
+	got := compile(t, p, "Print[Assoc[1, 2, HashMap[]]]\nDef[F, Args[x], Match[G[x], 1, 2, 3]]")
+
+	for _, want := range []string{"def eicg_print(", "def eicg_assoc(", "eicg_print(eicg_assoc(1, 2, dict()))", "lambda eicg_subject:"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q is not in the output:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, DefaultHelperPrefix) {
+		t.Errorf("default prefix is in the output:\n%s", got)
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	expectPython(t, "Def[N = List[1e10, 2.5e-3, 1.5, 42]]", "N = [1e10, 2.5e-3, 1.5, 42]")
+
+	// Python accepts digit separators, so they are kept
+	expectPython(t, "Def[N = 1_000]", "N = 1_000")
+}
+
+func TestChars(t *testing.T) {
+	expectPython(t, `Def[C = List['a', '\n', '\'', '"']]`, `C = ['a', '\n', '\'', '"']`)
+}
+
+func TestUnderscoreNames(t *testing.T) {
+	expectPython(t, "Def[_private, Args[_, __x], __x]", "_private = lambda _, __x: __x")
+}
+
+func TestStrictUnknownFunctions(t *testing.T) {
+	strict := func(src string) error {
+		_, err := compileErr(t, &Printer{Strict: true}, src)
+		return err
+	}
+
+	// Typo is an error in strict mode only
+	expectPython(t, "Pirnt[x]", "Pirnt(x)")
+	if err := strict("Pirnt[x]"); !errors.Is(err, ErrInvalidCall) || !strings.Contains(err.Error(), "unknown function Pirnt") {
+		t.Errorf("expected unknown function error, got %v", err)
+	}
+
+	// Defined functions, parameters, bindings and python builtins are known
+	for _, src := range []string{
+		"Print[len[xs]]",
+		"Def[F, Args[x], x]\nPrint[F[1]]",
+		"Print[F[1]]\nDef[F, Args[x], x]",
+		"Def[F, Args[g], g[1]]",
+		"Def[F = Let[g = str, g[1]]]",
+		"Def[F, Args[], Do[Def[G, Args[], 1], G[]]]",
+		"Def[F, Args[], Do[g = str, g[1]]]",
+		"Import[\"os\"]\nDef[F, Args[], os.getcwd[]]",
+	} {
+		if err := strict(src); err != nil {
+			t.Errorf("%q: unexpected error: %s", src, err)
+		}
+	}
+
+	// Names are visible only in their scope
+	for _, src := range []string{
+		"Def[F, Args[g], g[1]]\nPrint[g[1]]",
+		"Def[F, Args[], Do[Def[G, Args[], 1], G[]]]\nPrint[G[]]",
+	} {
+		if err := strict(src); !errors.Is(err, ErrInvalidCall) {
+			t.Errorf("%q: expected an error, got %v", src, err)
+		}
+	}
+}
+
+func TestAssocIn(t *testing.T) {
+	got := compile(t, &Printer{}, "Def[M = AssocIn[Path[\"a\", \"b\"], 1, HashMap[]]]")
+	if !strings.HasSuffix(got, "M = builtin__assoc_in([\"a\", \"b\"], 1, dict())") || strings.Count(got, "def builtin__assoc_in(") != 1 {
+		t.Errorf("got:\n%s", got)
+	}
+
+	out := compile(t, &Printer{}, "Def[M = AssocIn[Path[\"a\", \"b\"], 1, HashMap[]]]\nPrint[AssocIn[Path[\"a\", \"c\"], 2, M]]")
+	if got := runPython(t, out); got != "{'a': {'b': 1, 'c': 2}}\n" {
+		t.Errorf("got %q", got)
+	}
+
+	expectInvalid(t, "Print[AssocIn[Path[\"a\"], 1]]")
+	expectInvalid(t, "Print[Path[]]")
+}
+
+func TestGetIn(t *testing.T) {
+	got := compile(t, &Printer{}, "Def[V = GetIn[Path[\"a\", \"b\"], m]]")
+	if !strings.HasSuffix(got, "V = builtin__get_in([\"a\", \"b\"], m)") {
+		t.Errorf("got:\n%s", got)
+	}
+
+	src := "Def[M = AssocIn[Path[\"a\", \"b\"], 1, HashMap[]]]\n" +
+		"Print[GetIn[Path[\"a\", \"b\"], M]]\n" +
+		"Print[GetIn[Path[\"a\", \"x\"], M]]\n" +
+		"Print[GetIn[Path[\"x\", \"b\"], M]]\n" +
+		"Print[GetIn[Path[\"a\", \"b\", \"c\"], M]]"
+	if got := runPython(t, compile(t, &Printer{}, src)); got != "1\nNone\nNone\nNone\n" {
+		t.Errorf("got %q", got)
+	}
+
+	expectInvalid(t, "Print[GetIn[Path[\"a\"]]]")
+}
+
+func TestUpdate(t *testing.T) {
+	got := compile(t, &Printer{}, "Def[M = Update[\"count\", F, m]]")
+	if !strings.HasSuffix(got, "M = builtin__update(\"count\", F, m)") {
+		t.Errorf("got:\n%s", got)
+	}
+
+	src := "Def[Next, Args[x], Inc[x]]\nPrint[Update[\"count\", Next, Assoc[\"count\", 1, HashMap[]]]]"
+	if got := runPython(t, compile(t, &Printer{}, src)); got != "{'count': 2}\n" {
+		t.Errorf("got %q", got)
+	}
+
+	expectInvalid(t, "Print[Update[\"count\", F]]")
+}
+
+func TestKeysAndValues(t *testing.T) {
+	expectPython(t, "Def[K = Keys[m]]", "K = list(m.keys())")
+	expectPython(t, "Def[V = Values[m]]", "V = list(m.values())")
+
+	expectInvalid(t, "Print[Keys[]]")
+	expectInvalid(t, "Print[Values[a, b]]")
+}
+
+func TestMerge(t *testing.T) {
+	expectPython(t, "Def[M = Merge[a, b]]", "M = {**a, **b}")
+	expectPython(t, "Def[M = Merge[a, HashMap[\"k\", 1], c]]", "M = {**a, **{\"k\": 1}, **c}")
+
+	src := "Print[Merge[HashMap[\"a\", 1, \"b\", 1], HashMap[\"b\", 2]]]"
+	if got := runPython(t, compile(t, &Printer{}, src)); got != "{'a': 1, 'b': 2}\n" {
+		t.Errorf("got %q", got)
+	}
+
+	expectInvalid(t, "Print[Merge[a]]")
+}
+
+func TestApply(t *testing.T) {
+	expectPython(t, "Def[R = Apply[f, List[1, 2, 3]]]", "R = f(*[1, 2, 3])")
+	expectPython(t, "Def[R = Apply[max, xs]]", "R = max(*xs)")
+
+	expectInvalid(t, "Print[Apply[f]]")
+	expectInvalid(t, "Print[Apply[f, xs, ys]]")
+}
+
+func TestPartial(t *testing.T) {
+	expectPython(t, "Def[F = Partial[f, 1, 2]]", "import functools\n\nF = functools.partial(f, 1, 2)")
+	expectPython(t, "Def[F = Partial[f]]\nDef[G = Partial[g, 1]]", "import functools\n\nF = functools.partial(f)\nG = functools.partial(g, 1)")
+
+	src := "Def[Base2 = Partial[int, base = 2]]\nPrint[Base2[\"101\"]]"
+	if got := runPython(t, compile(t, &Printer{}, src)); got != "5\n" {
+		t.Errorf("got %q", got)
+	}
+
+	expectInvalid(t, "Print[Partial[]]")
+	expectInvalid(t, "Print[Partial[base = 2]]")
+}
+
+func TestCompose(t *testing.T) {
+	expectPython(t, "Def[H = Compose[f, g]]", "H = (lambda builtin__argument: f(g(builtin__argument)))")
+	expectPython(t, "Def[H = Compose[f, g, h]]", "H = (lambda builtin__argument: f(g(h(builtin__argument))))")
+
+	// Names of the outer function are not shadowed by the parameter of the composition
+	out := compile(t, &Printer{}, "Def[Scale, Args[x], Compose[Partial[pow, x], abs]]\nPrint[Call[Scale[2], 3]]")
+	if got := runPython(t, out); got != "8\n" {
+		t.Errorf("got %q", got)
+	}
+
+	expectInvalid(t, "Print[Compose[f]]")
+}
+
+func TestSort(t *testing.T) {
+	expectPython(t, "Def[S = Sort[xs]]", "S = sorted(xs)")
+	expectPython(t, "Def[S = Sort[xs, len]]", "S = sorted(xs, key=len)")
+	expectPython(t, "Def[S = Sort[xs, len, reverse = True]]", "S = sorted(xs, key=len, reverse=True)")
+
+	expectInvalid(t, "Print[Sort[]]")
+	expectInvalid(t, "Print[Sort[xs, len, abs]]")
+}
+
+func TestZip(t *testing.T) {
+	expectPython(t, "Def[Z = Zip[List[1, 2], List[3, 4]]]", "Z = list(zip([1, 2], [3, 4]))")
+	expectPython(t, "Def[Z = Zip[a, b, c]]", "Z = list(zip(a, b, c))")
+
+	expectInvalid(t, "Print[Zip[a]]")
+}
+
+func TestEnumerate(t *testing.T) {
+	expectPython(t, "Def[E = Enumerate[xs]]", "E = list(enumerate(xs))")
+	expectPython(t, "Def[E = Enumerate[xs, 1]]", "E = list(enumerate(xs, 1))")
+
+	expectInvalid(t, "Print[Enumerate[]]")
+	expectInvalid(t, "Print[Enumerate[xs, 1, 2]]")
+}
+
+func TestIn(t *testing.T) {
+	expectPython(t, "Def[Found = In[1, List[1, 2, 3]]]", "Found = (1 in [1, 2, 3])")
+	expectPython(t, "Def[Found = In[\"a\", s]]", "Found = (\"a\" in s)")
+
+	expectInvalid(t, "Print[In[1]]")
+}
+
+func TestNe(t *testing.T) {
+	expectPython(t, "Def[Sign, Args[x], Cond[Ne[x, 0], 1, 0]]", "Sign = lambda x: 1 if (x != 0) else 0")
+
+	expectInvalid(t, "Print[Ne[x]]")
+}
+
+func TestModAndPow(t *testing.T) {
+	expectPython(t, "Def[Odd, Args[x], Mod[x, 2]]", "Odd = lambda x: (x % 2)")
+	expectPython(t, "Def[Square, Args[x], Pow[x, 2]]", "Square = lambda x: (x ** 2)")
+
+	expectInvalid(t, "Print[Mod[1]]")
+	expectInvalid(t, "Print[Pow[1, 2, 3]]")
+}
+
+func TestPlainPrint(t *testing.T) {
+	got := compile(t, &Printer{PlainPrint: true}, "Print[1, 2]\nPrint[]")
+	if want := "print(1, 2)\nprint()"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if strings.Contains(got, "def ") {
+		t.Errorf("plain print emits a prelude:\n%s", got)
+	}
+
+	// By default the helper is emitted, because `Print` gives its first argument
+	if got := compile(t, &Printer{}, "Print[1]"); !strings.Contains(got, "def builtin__print") {
+		t.Errorf("no print helper:\n%s", got)
+	}
+}
+
+func TestInlineAssoc(t *testing.T) {
+	src := "Def[A = HashMap[]]\nDef[B = Assoc[\"k\", 1, A]]\nPrint[A, B]"
+
+	// The helper changes the object itself
+	helper := compile(t, &Printer{PlainPrint: true}, src)
+	if !strings.Contains(helper, "B = builtin__assoc(\"k\", 1, A)") {
+		t.Errorf("no helper call:\n%s", helper)
+	}
+	if got := runPython(t, helper); got != "{'k': 1} {'k': 1}\n" {
+		t.Errorf("helper: got %q", got)
+	}
+
+	// The inline form makes a copy and emits no helper
+	inline := compile(t, &Printer{PlainPrint: true, InlineAssoc: true}, src)
+	if want := "A = dict()\nB = {**A, \"k\": 1}\nprint(A, B)"; inline != want {
+		t.Errorf("inline: got %q, want %q", inline, want)
+	}
+	if got := runPython(t, inline); got != "{} {'k': 1}\n" {
+		t.Errorf("inline: got %q", got)
+	}
+}
+
+// TestArity - builtins with a wrong number of arguments are errors, rather than broken python or a panic.
+func TestArity(t *testing.T) {
+	for _, src := range []string{
+		"Print[Map[f]]",
+		"Print[Call[]]",
+		"Print[Call[x = 1]]",
+		"Print[Assoc[1, 2]]",
+		"Print[Has[1]]",
+		"Print[Inc[]]",
+	} {
+		expectInvalid(t, src)
+
+		for _, p := range []*Printer{{InlineAssoc: true}, {PlainPrint: true}} {
+			if _, err := compileErr(t, p, src); !errors.Is(err, ErrInvalidCall) {
+				t.Errorf("%q: expected ErrInvalidCall, got %v", src, err)
+			}
+		}
+	}
+}
+
+func TestMalformedParams(t *testing.T) {
+	for _, src := range []string{
+		"Def[F, Args[1], x]",
+		"Def[F, Args[\"a\"], x]",
+		"Def[F, Args[HashMap[1]], x]",
+		"Def[F, Args[HashMap[]], x]",
+		"Def[F, Args[HashMap[a, b]], x]",
+		"Def[F, Args[Rest[1]], x]",
+		"Def[F, Args[Do[x]], Do[x]]",
+	} {
+		expectInvalid(t, src)
+	}
+
+	// Assignment to anything but a name can't be parsed, but a transformation pass may produce it
+	number := parser.LiteralNumberExpression{Value: "1"}
+	for _, e := range []parser.Expression{
+		parser.CallExpression{Call: "Def", Args: []parser.Expression{parser.AssignmentExpression{Lhs: number, Rhs: number}}},
+		parser.CallExpression{Call: "Def", Args: []parser.Expression{
+			parser.VariableReferenceExpression{Value: "F"},
+			parser.CallExpression{Call: "Args", Args: []parser.Expression{parser.AssignmentExpression{Lhs: number, Rhs: number}}},
+			number,
+		}},
+	} {
+		p := Printer{}
+		if _, err := p.String(parser.BlockStatement{Expressions: []parser.Expression{e}}); !errors.Is(err, ErrInvalidCall) {
+			t.Errorf("%#v: expected ErrInvalidCall, got %v", e, err)
+		}
+	}
+}
+
+// TestDeterministic - the same source always gives the same bytes, with any order of map iteration.
+func TestDeterministic(t *testing.T) {
+	src := `Import["os"]
+Import["json"]
+ImportFrom["sys", "argv"]
+Def[M = HashMap["z", 1, "a", 2, "m", 3, 'c', 4, 5, 6]]
+Def[S = Set[9, 3, 7, 1, "b", "a"]]
+Def[P = GetIn[Path["a", "b"], Update["z", Inc, Assoc["k", 1, M]]]]
+Print[Partial[pow, 2], Sort[Keys[M]], AssocIn[Path["x", "y"], 1, HashMap[]]]
+`
+	want := compile(t, &Printer{}, src)
+	if !strings.Contains(want, `M = {"z": 1, "a": 2, "m": 3, 'c': 4, 5: 6}`) || !strings.Contains(want, `S = {9, 3, 7, 1, "b", "a"}`) {
+		t.Errorf("literals are not in source order:\n%s", want)
+	}
+
+	for i := 0; i < 100; i++ {
+		if got := compile(t, &Printer{}, src); got != want {
+			t.Fatalf("output differs on run %d:\n%s\nfirst:\n%s", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package python
+
+import "github.com/fuale/eicg/internal/parser"
+
+// scope - is a set of names visible in a function, or at the top level.
+// Scopes are tracked only in strict mode, to tell user functions from typos.
+type scope map[string]bool
+
+// pythonBuiltins - are python functions, which can be called without a definition.
+var pythonBuiltins = scope{
+	"abs": true, "all": true, "any": true, "bool": true, "callable": true, "chr": true,
+	"dict": true, "dir": true, "divmod": true, "enumerate": true, "filter": true, "float": true,
+	"format": true, "getattr": true, "hasattr": true, "hash": true, "id": true, "input": true,
+	"int": true, "isinstance": true, "iter": true, "len": true, "list": true, "map": true,
+	"max": true, "min": true, "next": true, "open": true, "ord": true, "pow": true,
+	"print": true, "range": true, "repr": true, "reversed": true, "round": true, "set": true,
+	"setattr": true, "sorted": true, "str": true, "sum": true, "super": true, "tuple": true,
+	"type": true, "zip": true, "Exception": true, "ValueError": true, "TypeError": true,
+	"KeyError": true, "IndexError": true, "RuntimeError": true,
+}
+
+// IsBuiltin - tells whether the name is a python function, which can be called without a definition.
+func IsBuiltin(name string) bool {
+	return pythonBuiltins[name]
+}
+
+// pushScope - opens a scope of a function or `Let`, it must be closed with `popScope`.
+func (p *Printer) pushScope() {
+	p.scopes = append(p.scopes, scope{})
+}
+
+func (p *Printer) popScope() {
+	p.scopes = p.scopes[:len(p.scopes)-1]
+}
+
+// declare - adds names to the innermost scope.
+func (p *Printer) declare(names ...string) {
+	if len(p.scopes) == 0 {
+		p.pushScope()
+	}
+
+	for _, name := range names {
+		p.scopes[len(p.scopes)-1][name] = true
+	}
+}
+
+// defined - tells whether a call to the name is known to be valid: it is
+// a python builtin, or it is declared in one of the enclosing scopes.
+func (p *Printer) defined(name string) bool {
+	if IsBuiltin(name) {
+		return true
+	}
+
+	for i := len(p.scopes) - 1; i >= 0; i-- {
+		if p.scopes[i][name] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// declareParams - declares names of function parameters, see `printParams` for their shapes.
+func (p *Printer) declareParams(args []parser.Expression) {
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case parser.VariableReferenceExpression:
+			p.declare(arg.Value)
+		case parser.AssignmentExpression:
+			p.declareTarget(arg.Lhs)
+		case parser.CallExpression:
+			// `Args[...]`, `HashMap[name]` and `Rest[name]`
+			p.declareParams(arg.Args)
+		}
+	}
+}
+
+// declareTarget - declares names assigned to, including destructuring.
+func (p *Printer) declareTarget(e parser.Expression) {
+	switch e := e.(type) {
+	case parser.VariableReferenceExpression:
+		p.declare(e.Value)
+	case parser.TupleExpression:
+		for _, element := range e.Elements {
+			p.declareTarget(element)
+		}
+	}
+}
+
+// declareStatements - declares names defined by statements of a function body or the top level.
+// In python they are visible in the whole function, even before the definition,
+// so they are declared before printing the body.
+func (p *Printer) declareStatements(body []parser.Expression) {
+	for _, stmt := range body {
+		switch stmt := stmt.(type) {
+		case parser.AssignmentExpression:
+			p.declareTarget(stmt.Lhs)
+		case parser.CallExpression:
+			switch stmt.Call {
+			case "Def", "Class":
+				if len(stmt.Args) > 0 {
+					p.declareTarget(stmt.Args[0])
+					if a, ok := stmt.Args[0].(parser.AssignmentExpression); ok {
+						p.declareTarget(a.Lhs)
+					}
+				}
+			case "Import":
+				for _, a := range stmt.Args {
+					if module, ok := a.(parser.LiteralStringExpression); ok {
+						p.declare(module.Value)
+					}
+				}
+			case "ImportFrom":
+				for i, a := range stmt.Args {
+					if name, ok := a.(parser.LiteralStringExpression); ok && i > 0 {
+						p.declare(name.Value)
+					}
+				}
+			case "Try":
+				for _, a := range stmt.Args {
+					p.declareStatements(p.statements(a))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package scheme
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fuale/eicg/internal/parser"
+)
+
+var ErrInvalidCall = errors.New("invalid call")
+
+// Printer - prints AST as a Scheme program.
+//
+// Since eicg's surface syntax is already Lisp-like, this printer is almost
+// a pretty-printer: square brackets become parentheses and builtins are
+// renamed to their Scheme counterparts. Hash tables are SRFI-69 ones.
+//
+// Scheme has no default parameter values, so `x = value` bindings in
+// `Let` and `Def` are lowered to a `let` around the body. That means
+// a caller can't override them, which is fine for the way eicg uses them.
+type Printer struct {
+	// err - is the first error occurred while printing.
+	// Printing continues after the error, but the output is discarded.
+	err error
+
+	// location - is the location of the top-level expression being printed, reported with recovered panics.
+	location parser.Location
+}
+
+// String - prints the AST as a scheme program.
+//
+// Unexpected AST, which isn't produced by the parser, may panic the printer.
+// The panic is returned as `parser.ErrInternal` with the location of the top-level expression.
+func (p *Printer) String(ast parser.Statement) (out string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			out, err = "", fmt.Errorf("%w: %v near %s", parser.ErrInternal, r, p.location.String())
+		}
+	}()
+
+	st := p.printStatement(ast)
+	if p.err != nil {
+		return "", p.err
+	}
+
+	return st, nil
+}
+
+// errorf - records the first error and returns a placeholder
+// so callers don't have to check for errors on every step.
+func (p *Printer) errorf(format string, a ...any) string {
+	if p.err == nil {
+		p.err = fmt.Errorf("%w: %s", ErrInvalidCall, fmt.Sprintf(format, a...))
+	}
+
+	return "<error>"
+}
+
+func (p *Printer) printStatement(s parser.Statement) string {
+	switch s := s.(type) {
+	case parser.BlockStatement:
+		expressions := make([]string, 0)
+		for _, ee := range s.Expressions {
+			p.location = parser.LocationOf(ee)
+			expressions = append(expressions, p.printExpression(ee))
+		}
+		return strings.Join(expressions, "\n")
+	default:
+		return p.errorf("unsupported statement")
+	}
+}
+
+func (p *Printer) printExpression(e parser.Expression) string {
+	switch e := e.(type) {
+	case parser.CallExpression:
+		// Definitions print their arguments on their own,
+		// so we handle them before printing arguments as expressions.
+		if e.Call == "Def" {
+			if len(e.Args) == 0 {
+				return p.errorf("Def requires a name at %s", e.Location)
+			}
+
+			if defname, ok := e.Args[0].(parser.VariableReferenceExpression); ok {
+				if len(e.Args) < 3 {
+					return p.errorf("Def of %s requires parameters and a body at %s", defname.Value, e.Location)
+				}
+
+				params := make([]string, 0)
+				bindings := make([]string, 0)
+				if paramDef, ok := e.Args[1].(parser.CallExpression); ok && paramDef.Call == "Args" {
+					params, bindings = p.printParams(paramDef.Args)
+				}
+
+				signature := strings.Join(append([]string{defname.Value}, params...), " ")
+				return fmt.Sprintf("(define (%s) %s)", signature, p.wrapBindings(bindings, p.printExpression(e.Args[2])))
+			}
+
+			if a, ok := e.Args[0].(parser.AssignmentExpression); ok {
+				name, ok := a.Lhs.(parser.VariableReferenceExpression)
+				if !ok {
+					return p.errorf("Def can assign only to a name at %s", a.Location)
+				}
+				return fmt.Sprintf("(define %s %s)", name.Value, p.printExpression(a.Rhs))
+			}
+
+			return p.errorf("Def requires a name at %s", e.Location)
+		}
+
+		if e.Call == "Let" {
+			// The last argument is the body, so there must be at least one
+			if len(e.Args) == 0 {
+				return p.errorf("Let requires at least one argument: the body")
+			}
+
+			params, bindings := p.printParams(e.Args[:len(e.Args)-1])
+			body := p.wrapBindings(bindings, p.printExpression(e.Args[len(e.Args)-1]))
+			return fmt.Sprintf("(lambda (%s) %s)", strings.Join(params, " "), body)
+		}
+
+		if e.Callee != nil {
+			return p.errorf("method calls are not supported in scheme at %s", e.Location)
+		}
+		if e.Call == "" {
+			return p.errorf("call has neither a name nor a callee at %s", e.Location)
+		}
+
+		args := make([]string, 0)
+		for _, a := range e.Args {
+			args = append(args, p.printExpression(a))
+		}
+
+		if e.Call == "Print" {
+			return fmt.Sprintf("(print %s)", strings.Join(args, " "))
+		}
+
+		// HashMap[k1, v1, k2, v2] - is a hash table of key and value pairs
+		if e.Call == "HashMap" {
+			if len(args) == 0 {
+				return "(make-hash-table)"
+			}
+			if len(args)%2 != 0 {
+				return p.errorf("HashMap requires pairs of key and value")
+			}
+
+			pairs := make([]string, 0, len(args)/2)
+			for i := 0; i < len(args); i += 2 {
+				pairs = append(pairs, fmt.Sprintf("(cons %s %s)", args[i], args[i+1]))
+			}
+
+			return fmt.Sprintf("(alist->hash-table (list %s))", strings.Join(pairs, " "))
+		}
+
+		if e.Call == "Map" {
+			if len(args) < 2 {
+				return p.errorf("Map requires a function and at least one list")
+			}
+			return fmt.Sprintf("(map %s)", strings.Join(args, " "))
+		}
+
+		if e.Call == "List" {
+			return fmt.Sprintf("(list %s)", strings.Join(args, " "))
+		}
+
+		if e.Call == "Call" {
+			if len(args) == 0 {
+				return p.errorf("Call requires at least one argument: the function")
+			}
+			return fmt.Sprintf("(%s)", strings.Join(args, " "))
+		}
+
+		if e.Call == "Assoc" {
+			if len(args) != 3 {
+				return p.errorf("Assoc accepts exactly three arguments: key, value and object")
+			}
+			return fmt.Sprintf("(begin (hash-table-set! %s %s %s) %s)", args[2], args[0], args[1], args[2])
+		}
+
+		if e.Call == "Has" {
+			if len(args) != 2 {
+				return p.errorf("Has accepts exactly two arguments: key and object")
+			}
+			return fmt.Sprintf("(hash-table-exists? %s %s)", args[1], args[0])
+		}
+
+		if e.Call == "Get" {
+			if len(args) != 2 {
+				return p.errorf("Get accepts exactly two arguments: key and object")
+			}
+			return fmt.Sprintf("(hash-table-ref/default %s %s #f)", args[1], args[0])
+		}
+
+		if e.Call == "Eq" {
+			if len(args) != 2 {
+				return p.errorf("Eq accepts exactly two arguments")
+			}
+			return fmt.Sprintf("(equal? %s %s)", args[0], args[1])
+		}
+
+		if e.Call == "Cond" {
+			// Cond[t1, e1, t2, e2, ..., default] - pairs of test and result, then default
+			if len(args) < 3 || len(args)%2 == 0 {
+				return p.errorf("Cond requires an odd number of arguments, at least three")
+			}
+
+			clauses := make([]string, 0, len(args)/2+1)
+			for i := 0; i < len(args)-1; i += 2 {
+				clauses = append(clauses, fmt.Sprintf("(%s %s)", args[i], args[i+1]))
+			}
+			clauses = append(clauses, fmt.Sprintf("(else %s)", args[len(args)-1]))
+
+			return fmt.Sprintf("(cond %s)", strings.Join(clauses, " "))
+		}
+
+		if e.Call == "Inc" {
+			for i := range args {
+				args[i] = fmt.Sprintf("(+ %s 1)", args[i])
+			}
+			return strings.Join(args, " ")
+		}
+
+		return fmt.Sprintf("(%s)", strings.Join(append([]string{e.Call}, args...), " "))
+	case parser.LiteralNumberExpression:
+		return NormalizeNumber(e.Value)
+	case parser.LiteralStringExpression:
+		return fmt.Sprintf("\"%s\"", e.Value)
+	case parser.LiteralCharExpression:
+		if name, ok := charNames[e.Value]; ok {
+			return "#\\" + name
+		}
+		return "#\\" + strings.TrimPrefix(e.Value, "\\")
+	case parser.VariableReferenceExpression:
+		return e.Value
+	}
+
+	return p.errorf("unsupported expression at %s", parser.LocationOf(e))
+}
+
+// printParams - splits parameter list into plain parameters and `let` bindings.
+// Parameters with a value (`x = 1`, `HashMap[kv]`) can't be expressed as a
+// Scheme parameter, so they become bindings instead.
+func (p *Printer) printParams(args []parser.Expression) (params []string, bindings []string) {
+	params = make([]string, 0)
+	bindings = make([]string, 0)
+
+	for _, arg := range args {
+		if argname, ok := arg.(parser.VariableReferenceExpression); ok {
+			params = append(params, argname.Value)
+		} else if subargs, ok := arg.(parser.CallExpression); ok && subargs.Call == "Args" {
+			subparams, subbindings := p.printParams(subargs.Args)
+			params = append(params, subparams...)
+			bindings = append(bindings, subbindings...)
+		} else if subargs, ok := arg.(parser.CallExpression); ok && subargs.Call == "HashMap" {
+			if len(subargs.Args) != 1 {
+				bindings = append(bindings, p.errorf("HashMap currently accept only one argument"))
+			} else if name, ok := subargs.Args[0].(parser.VariableReferenceExpression); ok {
+				bindings = append(bindings, fmt.Sprintf("(%s (make-hash-table))", name.Value))
+			} else {
+				bindings = append(bindings, p.errorf("HashMap accepts only a parameter name at %s", subargs.Location))
+			}
+		} else if a, ok := arg.(parser.AssignmentExpression); ok {
+			if name, ok := a.Lhs.(parser.VariableReferenceExpression); ok {
+				bindings = append(bindings, fmt.Sprintf("(%s %s)", name.Value, p.printExpression(a.Rhs)))
+			} else {
+				bindings = append(bindings, p.errorf("default value must be assigned to a parameter name at %s", a.Location))
+			}
+		} else {
+			params = append(params, p.errorf("parameter must be a name at %s", parser.LocationOf(arg)))
+		}
+	}
+
+	return params, bindings
+}
+
+// wrapBindings - wraps body in `let` when there are any bindings.
+func (p *Printer) wrapBindings(bindings []string, body string) string {
+	if len(bindings) == 0 {
+		return body
+	}
+
+	return fmt.Sprintf("(let (%s) %s)", strings.Join(bindings, " "), body)
+}
+
+// charNames - are scheme names of chars, which can't be written as is after `#\`.
+var charNames = map[string]string{
+	" ":   "space",
+	"\\n": "newline",
+	"\\t": "tab",
+	"\\r": "return",
+	"\\0": "null",
+}
+
+// NormalizeNumber - converts the lexed number to scheme's syntax,
+// which has no digit separators, so `_` are dropped: `1_000` is `1000`.
+func NormalizeNumber(value string) string {
+	return strings.ReplaceAll(value, "_", "")
+}
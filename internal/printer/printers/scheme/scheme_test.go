@@ -0,0 +1,115 @@
+package scheme
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fuale/eicg/internal/lexer"
+	"github.com/fuale/eicg/internal/parser"
+)
+
+// compile - prints the source as scheme, returning the printer error.
+func compile(t *testing.T, src string) (string, error) {
+	t.Helper()
+
+	ast, err := parser.New(lexer.New(strings.NewReader(src))).Parse()
+	if err != nil {
+		t.Fatalf("parsing %q: %s", src, err)
+	}
+
+	p := Printer{}
+	return p.String(ast)
+}
+
+func TestProgram(t *testing.T) {
+	src := `
+Def[Square, Args[x], Mul[x, x]]
+Def[Greeting = "hello"]
+Print[Map[Square, List[1, 2, 3]]]
+Print[Cond[Eq[Greeting, "hi"], 'a', Eq[Greeting, "hello"], 'b', ' ']]
+Def[Count, Args[x, HashMap[seen], n = 0], Assoc[x, Inc[n], seen]]
+`
+	want := `(define (Square x) (Mul x x))
+(define Greeting "hello")
+(print (map Square (list 1 2 3)))
+(print (cond ((equal? Greeting "hi") #\a) ((equal? Greeting "hello") #\b) (else #\space)))
+(define (Count x) (let ((seen (make-hash-table)) (n 0)) (begin (hash-table-set! seen x (+ n 1)) seen)))`
+
+	got, err := compile(t, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestInvalid - covers programs which used to panic or exit the process.
+func TestInvalid(t *testing.T) {
+	for _, src := range []string{
+		"xs.append[1]",
+		"Def[F, Args[HashMap[]], 1]",
+		"Def[F, Args[HashMap[a, b]], 1]",
+		"Def[F, Args[HashMap[1]], 1]",
+		"Def[F, Args[1], 1]",
+		"Print[Let[]]",
+		"Def[]",
+		"Def[F]",
+		"Def[F, Args[x]]",
+		"Def[1]",
+		"Print[Eq[1]]",
+		"Print[Map[]]",
+		"Print[Call[]]",
+		"Print[Assoc[1, 2]]",
+		"Print[Has[1]]",
+		"Print[Get[1]]",
+		"Print[Cond[1, 2]]",
+		"Print[xs.append[1]]",
+		"Print[HashMap[1]]",
+	} {
+		if _, err := compile(t, src); !errors.Is(err, ErrInvalidCall) {
+			t.Errorf("%q: expected ErrInvalidCall, got %v", src, err)
+		}
+	}
+}
+
+// TestInvalidAST - covers shapes, which the parser doesn't produce, but a transformation pass might.
+func TestInvalidAST(t *testing.T) {
+	ast := parser.BlockStatement{Expressions: []parser.Expression{
+		parser.CallExpression{Call: "Def", Args: []parser.Expression{
+			parser.AssignmentExpression{Lhs: parser.LiteralNumberExpression{Value: "1"}, Rhs: parser.LiteralNumberExpression{Value: "2"}},
+		}},
+	}}
+
+	p := Printer{}
+	if _, err := p.String(ast); !errors.Is(err, ErrInvalidCall) {
+		t.Errorf("expected ErrInvalidCall, got %v", err)
+	}
+}
+
+// TestNumbers - scheme has no digit separators, so they are dropped.
+func TestNumbers(t *testing.T) {
+	got, err := compile(t, "Def[N = 1_000]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "(define N 1000)" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestHashMap(t *testing.T) {
+	for src, want := range map[string]string{
+		"Def[M = HashMap[]]":                 "(define M (make-hash-table))",
+		"Def[M = HashMap[\"a\", 1, 'b', x]]": "(define M (alist->hash-table (list (cons \"a\" 1) (cons #\\b x))))",
+	} {
+		got, err := compile(t, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%q: got %q, want %q", src, got, want)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package typescript
+
+import (
+	"github.com/fuale/eicg/internal/parser"
+	"github.com/fuale/eicg/internal/printer/printers/javascript"
+)
+
+// Printer - prints AST as a TypeScript program.
+//
+// TypeScript output is the JavaScript one with type annotations:
+// literal numbers are `number`, literal strings are `string`, everything
+// else (including every `Def` parameter and return value) is `any`.
+type Printer struct{}
+
+func (p *Printer) String(ast parser.Statement) (string, error) {
+	jp := javascript.Printer{TypeScript: true}
+	return jp.String(ast)
+}
@@ -0,0 +1,74 @@
+package typescript
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/fuale/eicg/internal/lexer"
+	"github.com/fuale/eicg/internal/parser"
+	"github.com/fuale/eicg/internal/printer/printers/javascript"
+)
+
+const program = `
+Def[Square, Args[x], Mul[x, x]]
+Def[Greet, Args[name = "world", times = 3, HashMap[seen]], Assoc[name, times, seen]]
+Def[Limit = 10]
+Def[Name = "eicg"]
+Def[Anything = Square[2]]
+Print[Let[x = 1, Square[x]]]
+`
+
+func parse(t *testing.T, src string) parser.Statement {
+	t.Helper()
+
+	ast, err := parser.New(lexer.New(strings.NewReader(src))).Parse()
+	if err != nil {
+		t.Fatalf("parsing %q: %s", src, err)
+	}
+
+	return ast
+}
+
+func TestAnnotations(t *testing.T) {
+	p := Printer{}
+	got, err := p.String(parse(t, program))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"const Square = (x: any): any => Mul(x, x);",
+		`const Greet = (name: string = "world", times: number = 3, seen: any = {}): any => builtin__assoc(name, times, seen);`,
+		"const Limit: number = 10;",
+		`const Name: string = "eicg";`,
+		"const Anything: any = Square(2);",
+		"builtin__print(((x: number = 1): any => Square(x)));",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output doesn't contain %s:\n%s", want, got)
+		}
+	}
+}
+
+// annotation - matches type annotations, which is all typescript adds to javascript.
+var annotation = regexp.MustCompile(`: (any\[\]|any\b|number\b|string\b)`)
+
+// TestSameAsJavaScript - typescript output without annotations must be the javascript one.
+func TestSameAsJavaScript(t *testing.T) {
+	tp := Printer{}
+	ts, err := tp.String(parse(t, program))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jp := javascript.Printer{}
+	js, err := jp.String(parse(t, program))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stripped := annotation.ReplaceAllString(ts, ""); stripped != js {
+		t.Errorf("typescript without annotations:\n%s\njavascript:\n%s", stripped, js)
+	}
+}
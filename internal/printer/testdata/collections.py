@@ -0,0 +1,20 @@
+def builtin__assoc(k, v, obj):
+  obj[k] = v
+  return obj
+
+def builtin__get_in(path, obj):
+  for k in path:
+    if not isinstance(obj, dict) or k not in obj:
+      return None
+    obj = obj[k]
+  return obj
+
+def builtin__print(*args, **kwargs):
+  print(*args, **kwargs)
+  return args[0] if args else None
+
+M = {"a": 1, "b": 2}
+N = {**M, **builtin__assoc("c", 3, dict())}
+builtin__print(list(N.keys()),list(N.values()),(N.get("a")),builtin__get_in(["x", "y"], N))
+builtin__print(sorted([3, 1, 2]),list(zip([1, 2], ["a", "b"])),list(enumerate(["a"], 1)))
+builtin__print(set(),{1, 2},[1, 2, 3][1:],(1 in [1]))
\ No newline at end of file
@@ -0,0 +1,14 @@
+def builtin__print(*args, **kwargs):
+  print(*args, **kwargs)
+  return args[0] if args else None
+
+# Lambdas and functions
+Inc1 = lambda x: (x+1)
+
+
+def Parity(n):
+  return "even" if ((n % 2) == 0) else "odd"
+
+
+Greet = lambda name, greeting = "Hello": "{} {}".format(greeting, name)
+builtin__print(Inc1(1),Parity(3),Greet("world"),((lambda x = 10: builtin__print(x))()))
\ No newline at end of file
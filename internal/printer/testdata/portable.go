@@ -0,0 +1,14 @@
+package main
+
+import "fmt"
+
+var Greeting = "Hello"
+
+func Greet(name interface{}) interface{} {
+	return func() interface{} { fmt.Println(Greeting, name); return Greeting }()
+}
+
+func main() {
+	Greet("world")
+	fmt.Println(1000, 'c')
+}
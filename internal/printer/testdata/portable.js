@@ -0,0 +1,9 @@
+const builtin__print = (...args) => {
+  console.log(...args);
+  return args[0];
+};
+
+const Greeting = "Hello";
+const Greet = (name) => builtin__print(Greeting, name);
+Greet("world");
+builtin__print(1000, 'c');
\ No newline at end of file
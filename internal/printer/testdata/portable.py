@@ -0,0 +1,9 @@
+def builtin__print(*args, **kwargs):
+  print(*args, **kwargs)
+  return args[0] if args else None
+
+# Subset which every backend supports
+Greeting = "Hello"
+Greet = lambda name: builtin__print(Greeting,name)
+Greet("world")
+builtin__print(1000,'c')
\ No newline at end of file
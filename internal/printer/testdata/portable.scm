@@ -0,0 +1,4 @@
+(define Greeting "Hello")
+(define (Greet name) (print Greeting name))
+(Greet "world")
+(print 1000 #\c)
\ No newline at end of file
@@ -0,0 +1,9 @@
+#!/usr/bin/env bash
+
+Greeting="Hello"
+Greet() {
+  local name="$1"
+  echo "${Greeting}" "${name}"
+}
+Greet "world"
+echo "1000" "c"
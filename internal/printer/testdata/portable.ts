@@ -0,0 +1,9 @@
+const builtin__print = (...args: any[]): any => {
+  console.log(...args);
+  return args[0];
+};
+
+const Greeting: string = "Hello";
+const Greet = (name: any): any => builtin__print(Greeting, name);
+Greet("world");
+builtin__print(1000, 'c');
\ No newline at end of file
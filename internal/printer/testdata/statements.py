@@ -0,0 +1,22 @@
+import os
+from math import floor
+
+def builtin__print(*args, **kwargs):
+  print(*args, **kwargs)
+  return args[0] if args else None
+
+def Divide(a, b):
+  try:
+    q, r = divmod(a, b)
+    return q, r
+  except Exception as e:
+    builtin__print(e)
+    raise e
+
+
+def Swap(pair):
+  a, b = pair
+  return b, a
+
+
+builtin__print(Swap([1, 2]),Divide(7, 2),floor(2.5))
\ No newline at end of file